@@ -0,0 +1,219 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// RenderStats counts the rays a camera fires. CameraRays covers the rays every
+// pixel needs; ExtraRays are the ones adaptive sampling adds on top.
+type RenderStats struct {
+	CameraRays int64
+	ExtraRays  int64
+}
+
+func (s *RenderStats) Reset() {
+	atomic.StoreInt64(&s.CameraRays, 0)
+	atomic.StoreInt64(&s.ExtraRays, 0)
+}
+
+func (s *RenderStats) addCameraRays(n int64) {
+	if s != nil {
+		atomic.AddInt64(&s.CameraRays, n)
+	}
+}
+
+func (s *RenderStats) addExtraRays(n int64) {
+	if s != nil {
+		atomic.AddInt64(&s.ExtraRays, n)
+	}
+}
+
+func (s *RenderStats) GetCameraRays() int64 {
+	return atomic.LoadInt64(&s.CameraRays)
+}
+
+func (s *RenderStats) GetExtraRays() int64 {
+	return atomic.LoadInt64(&s.ExtraRays)
+}
+
+// adaptiveRay is the colour and coverage one adaptive sample brings back.
+type adaptiveRay struct {
+	color    Color
+	coverage float64
+}
+
+// adaptiveCorners holds the rays traced through pixel corners during one
+// render. Neighbouring tiles share the corners along their edges, so
+// each corner is traced, and counted, only once whichever tile gets to it
+// first.
+type adaptiveCorners struct {
+	x    int64
+	y    int64
+	cols int64
+	once []sync.Once
+	rays []adaptiveRay
+}
+
+// newAdaptiveCorners covers every corner of every pixel in the tiles.
+func newAdaptiveCorners(tiles []Tile) *adaptiveCorners {
+	if len(tiles) == 0 {
+		return &adaptiveCorners{}
+	}
+	x0, y0 := tiles[0].X, tiles[0].Y
+	x1, y1 := x0, y0
+	for _, t := range tiles {
+		x0, y0 = min64(x0, t.X), min64(y0, t.Y)
+		x1, y1 = max64(x1, t.X+t.Width), max64(y1, t.Y+t.Height)
+	}
+	n := (x1 - x0 + 1) * (y1 - y0 + 1)
+	return &adaptiveCorners{
+		x:    x0,
+		y:    y0,
+		cols: x1 - x0 + 1,
+		once: make([]sync.Once, n),
+		rays: make([]adaptiveRay, n),
+	}
+}
+
+// at is the ray through the top left corner of pixel (px, py).
+func (a *adaptiveCorners) at(c *Camera, w World, px, py int64) adaptiveRay {
+	i := (py-a.y)*a.cols + px - a.x
+	a.once[i].Do(func() {
+		a.rays[i] = c.adaptiveTrace(w, px, py, 0, 0)
+		c.Stats.addCameraRays(1)
+	})
+	return a.rays[i]
+}
+
+// renderAdaptiveTile traces a ray through every pixel corner in the tile, then
+// subdivides only the pixels whose corners disagree by more than
+// AdaptiveThreshold, down to AdaptiveDepth levels. Corners come from the
+// render's shared corners, or from the tile's own when corners is nil.
+func (c *Camera) renderAdaptiveTile(w World, t Tile, corners *adaptiveCorners, put func(x, y int64, col Color, alpha float64)) {
+	if corners == nil {
+		corners = newAdaptiveCorners([]Tile{t})
+	}
+	for y := t.Y; y < t.Y+t.Height; y++ {
+		for x := t.X; x < t.X+t.Width; x++ {
+			quad := [4]adaptiveRay{
+				corners.at(c, w, x, y),
+				corners.at(c, w, x+1, y),
+				corners.at(c, w, x, y+1),
+				corners.at(c, w, x+1, y+1),
+			}
+			r := c.adaptiveSample(w, x, y, 0, 0, 1, quad, 0)
+			put(x, y, r.color, r.coverage)
+		}
+	}
+}
+
+// adaptiveCameraSample is the sample at (x, y) in the pixel. With an
+// aperture or an open shutter it gets a lens position and time of its own,
+// which depend only on where the sample is in the frame, so a corner shared
+// by neighbouring pixels is the same ray from either side.
+func (c *Camera) adaptiveCameraSample(px, py int64, x, y float64) CameraSample {
+	s := NewCameraSample(x, y)
+	if c.Aperture <= 0 && c.ShutterClose <= c.ShutterOpen {
+		return s
+	}
+	// Subdivided positions are multiples of a power of two, so this grid
+	// gives every position in the frame a distinct seed
+	const grid = 1 << 16
+	rnd := c.sampleRand(px*grid+int64(x*grid), py*grid+int64(y*grid))
+	if c.Aperture > 0 {
+		s.LensX, s.LensY = concentricDisk(rnd.Float64(), rnd.Float64())
+	}
+	if c.ShutterClose > c.ShutterOpen {
+		s.Time = rnd.Float64()
+	}
+	return s
+}
+
+// adaptiveTrace traces the sample at (x, y) in the pixel.
+func (c *Camera) adaptiveTrace(w World, px, py int64, x, y float64) adaptiveRay {
+	color, coverage := c.traceSampleCoverage(w, px, py, c.adaptiveCameraSample(px, py, x, y))
+	return adaptiveRay{color: color, coverage: coverage}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// adaptiveSample resolves the square of the pixel at (x0, y0) with the given
+// size, whose corners are top-left, top-right, bottom-left, bottom-right.
+// With Alpha set, corners whose coverage disagrees are subdivided too, so
+// silhouettes against the background are resolved even where the colours
+// match.
+func (c *Camera) adaptiveSample(w World, px, py int64, x0, y0, size float64, quad [4]adaptiveRay, depth int) adaptiveRay {
+	colors := [4]Color{quad[0].color, quad[1].color, quad[2].color, quad[3].color}
+	spread := contrast(colors)
+	if c.Alpha {
+		spread = math.Max(spread, coverageContrast(quad))
+	}
+	if depth >= c.AdaptiveDepth || spread <= c.AdaptiveThreshold {
+		return averageRays(quad)
+	}
+
+	half := size / 2
+	top := c.adaptiveTrace(w, px, py, x0+half, y0)
+	left := c.adaptiveTrace(w, px, py, x0, y0+half)
+	centre := c.adaptiveTrace(w, px, py, x0+half, y0+half)
+	right := c.adaptiveTrace(w, px, py, x0+size, y0+half)
+	bottom := c.adaptiveTrace(w, px, py, x0+half, y0+size)
+	c.Stats.addExtraRays(5)
+
+	return averageRays([4]adaptiveRay{
+		c.adaptiveSample(w, px, py, x0, y0, half, [4]adaptiveRay{quad[0], top, left, centre}, depth+1),
+		c.adaptiveSample(w, px, py, x0+half, y0, half, [4]adaptiveRay{top, quad[1], centre, right}, depth+1),
+		c.adaptiveSample(w, px, py, x0, y0+half, half, [4]adaptiveRay{left, centre, quad[2], bottom}, depth+1),
+		c.adaptiveSample(w, px, py, x0+half, y0+half, half, [4]adaptiveRay{centre, right, bottom, quad[3]}, depth+1),
+	})
+}
+
+func averageRays(rays [4]adaptiveRay) adaptiveRay {
+	return adaptiveRay{
+		color:    rays[0].color.Add(rays[1].color).Add(rays[2].color).Add(rays[3].color).MultiplyScalar(0.25),
+		coverage: (rays[0].coverage + rays[1].coverage + rays[2].coverage + rays[3].coverage) / 4,
+	}
+}
+
+// coverageContrast is the spread of coverage across the rays.
+func coverageContrast(rays [4]adaptiveRay) float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, r := range rays {
+		lo = math.Min(lo, r.coverage)
+		hi = math.Max(hi, r.coverage)
+	}
+	return hi - lo
+}
+
+// contrast is the largest spread of any one channel across the colours.
+func contrast(colors [4]Color) float64 {
+	spread := 0.0
+	channels := []func(Color) float64{
+		func(c Color) float64 { return c.Red },
+		func(c Color) float64 { return c.Green },
+		func(c Color) float64 { return c.Blue },
+	}
+	for _, channel := range channels {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, col := range colors {
+			lo = math.Min(lo, channel(col))
+			hi = math.Max(hi, channel(col))
+		}
+		spread = math.Max(spread, hi-lo)
+	}
+	return spread
+}
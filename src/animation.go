@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// FramePattern names numbered frame files, counting from frame_0001.png.
+const FramePattern = "frame_%04d.png"
+
+// Animation collects the frames of a sequence in order, to be saved as
+// numbered image files or as an animated GIF.
+type Animation struct {
+	Frames []Canvas
+	// Encode is applied to every frame, whichever way it is saved
+	Encode EncodeOptions
+	// Delay is how long a GIF shows each frame, in hundredths of a second
+	Delay int
+	// LoopCount is how many times a GIF restarts: 0 loops forever, -1 plays
+	// once and n plays n+1 times
+	LoopCount int
+	// PaletteSize is how many colours, up to 256, a GIF's palette is cut to
+	PaletteSize int
+	// Palette, when set, is used for the GIF instead of cutting one from the
+	// frames
+	Palette color.Palette
+	Dither  bool
+}
+
+func NewAnimation() Animation {
+	return Animation{
+		Encode:      NewEncodeOptions(),
+		Delay:       4,
+		PaletteSize: 256,
+		Dither:      true,
+	}
+}
+
+func (a *Animation) AddFrame(c Canvas) {
+	a.Frames = append(a.Frames, c)
+}
+
+// FramePath is the file for frame n, counting from 1, of a sequence named
+// by pattern, which holds one %d verb such as FramePattern's.
+func FramePath(pattern string, n int) string {
+	return fmt.Sprintf(pattern, n)
+}
+
+// SaveFrames saves each frame to its FramePath, in the format the
+// pattern's extension names, and returns the paths.
+func (a *Animation) SaveFrames(pattern string) ([]string, error) {
+	if FramePath(pattern, 1) == FramePath(pattern, 2) {
+		return nil, fmt.Errorf("%q does not number its frames", pattern)
+	}
+	paths := []string{}
+	for i, frame := range a.Frames {
+		path := FramePath(pattern, i+1)
+		if err := frame.SaveWith(path, a.Encode); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// WriteGIF writes the frames as an animated GIF sharing one palette, so
+// colours do not flicker from frame to frame.
+func (a *Animation) WriteGIF(w io.Writer) error {
+	if len(a.Frames) == 0 {
+		return fmt.Errorf("an animation needs at least one frame")
+	}
+	width, height := a.Frames[0].Width, a.Frames[0].Height
+	frames := make([]*image.RGBA, len(a.Frames))
+	for i := range a.Frames {
+		f := &a.Frames[i]
+		if f.Width != width || f.Height != height {
+			return fmt.Errorf("frame %d is %dx%d, not %dx%d", i+1, f.Width, f.Height, width, height)
+		}
+		frames[i] = f.forDisplay("gif", a.Encode).toRGBA()
+	}
+	palette := a.Palette
+	if palette == nil {
+		size := a.PaletteSize
+		if size < 1 || size > 256 {
+			return fmt.Errorf("a GIF palette holds 1 to 256 colours, not %d", size)
+		}
+		palette = medianCut(frames, size)
+	}
+
+	out := &gif.GIF{LoopCount: a.LoopCount}
+	for _, frame := range frames {
+		p := image.NewPaletted(frame.Bounds(), palette)
+		if a.Dither {
+			draw.FloydSteinberg.Draw(p, p.Bounds(), frame, image.Point{})
+		} else {
+			draw.Draw(p, p.Bounds(), frame, image.Point{}, draw.Src)
+		}
+		out.Image = append(out.Image, p)
+		out.Delay = append(out.Delay, a.Delay)
+	}
+	return gif.EncodeAll(w, out)
+}
+
+func (a *Animation) SaveGIF(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(f)
+	err = a.WriteGIF(out)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type paletteEntry struct {
+	rgb   [3]uint8
+	count int
+}
+
+// medianCut picks up to n colours for the frames (Heckbert). The box of
+// colours with the widest channel is split where half its pixels fall
+// either side, until there are n boxes or none can be split, and each box
+// gives the mean of its pixels.
+func medianCut(frames []*image.RGBA, n int) color.Palette {
+	counts := map[[3]uint8]int{}
+	for _, f := range frames {
+		for i := 0; i < len(f.Pix); i += 4 {
+			counts[[3]uint8{f.Pix[i], f.Pix[i+1], f.Pix[i+2]}]++
+		}
+	}
+	entries := make([]paletteEntry, 0, len(counts))
+	for rgb, count := range counts {
+		entries = append(entries, paletteEntry{rgb, count})
+	}
+	// Map order is random; sorting keeps the palette the same run to run
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].rgb, entries[j].rgb
+		return a[0] < b[0] || a[0] == b[0] && (a[1] < b[1] || a[1] == b[1] && a[2] < b[2])
+	})
+
+	boxes := [][]paletteEntry{entries}
+	for len(boxes) < n {
+		widest, channel, span := -1, 0, 0
+		for i, box := range boxes {
+			for ch := 0; ch < 3; ch++ {
+				lo, hi := uint8(255), uint8(0)
+				for _, e := range box {
+					if e.rgb[ch] < lo {
+						lo = e.rgb[ch]
+					}
+					if e.rgb[ch] > hi {
+						hi = e.rgb[ch]
+					}
+				}
+				if len(box) > 1 && int(hi)-int(lo) > span {
+					widest, channel, span = i, ch, int(hi)-int(lo)
+				}
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		box := boxes[widest]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].rgb[channel] < box[j].rgb[channel] })
+		total := 0
+		for _, e := range box {
+			total += e.count
+		}
+		split, seen := 1, box[0].count
+		for split < len(box)-1 && seen+box[split].count <= total/2 {
+			seen += box[split].count
+			split++
+		}
+		boxes[widest] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := color.Palette{}
+	for _, box := range boxes {
+		var r, g, b, total int
+		for _, e := range box {
+			r += int(e.rgb[0]) * e.count
+			g += int(e.rgb[1]) * e.count
+			b += int(e.rgb[2]) * e.count
+			total += e.count
+		}
+		if total == 0 {
+			continue
+		}
+		palette = append(palette, color.RGBA{
+			R: uint8((r + total/2) / total),
+			G: uint8((g + total/2) / total),
+			B: uint8((b + total/2) / total),
+			A: 255,
+		})
+	}
+	return palette
+}
+
+// RenderTurntable renders frames of the world turning a full circle about
+// its y axis in front of the camera.
+func RenderTurntable(w World, c Camera, frames int) Animation {
+	a := NewAnimation()
+	base := c.Transform
+	for i := 0; i < frames; i++ {
+		turn := NewRotationY(2 * math.Pi * float64(i) / float64(frames))
+		c.SetTransform(base.MultiplyMatrix(turn))
+		a.AddFrame(c.Render(w))
+	}
+	return a
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// AOVNames are the arbitrary output variables a render can fill alongside
+// its colour, each taken from what the primary ray through a pixel hits:
+//
+//	depth      the distance T along the ray, 0 where it misses
+//	normal     the world space normal, with x, y and z in red, green and blue
+//	albedo     the material or pattern colour before lighting
+//	object_id  the hit object's GetID, 0 where the ray misses
+//	shadow     1 where the hit point is in shadow, 0 where it is lit or missed
+var AOVNames = []string{"depth", "normal", "albedo", "object_id", "shadow"}
+
+// AOVSample is what one ray saw for every AOV.
+type AOVSample struct {
+	Hit      bool
+	Depth    float64
+	Normal   Tuple
+	Albedo   Color
+	ObjectID int
+	Shadowed bool
+}
+
+// AOVAt traces r to its first hit without shading it.
+func (w *World) AOVAt(r Ray) AOVSample {
+	xs := w.Intersect(r)
+	hit, is := Hit(xs)
+	if !hit {
+		return AOVSample{}
+	}
+	comps := is.PrepareComputations(r, xs)
+	m := comps.Object.GetMaterial()
+	albedo := m.Color
+	if m.HasPattern {
+		albedo = m.Pattern.ColorAtObject(comps.Object, comps.OverPoint)
+	}
+	return AOVSample{
+		Hit:      true,
+		Depth:    comps.T,
+		Normal:   comps.Normalv,
+		Albedo:   albedo,
+		ObjectID: comps.Object.GetID(),
+		Shadowed: w.IsShadowedAt(comps.OverPoint, comps.Time),
+	}
+}
+
+// Color is the sample's value for the named AOV as it is stored in a
+// buffer.
+func (s AOVSample) Color(name string) Color {
+	switch name {
+	case "depth":
+		return NewColor(s.Depth, s.Depth, s.Depth)
+	case "normal":
+		return NewColor(s.Normal.X, s.Normal.Y, s.Normal.Z)
+	case "albedo":
+		return s.Albedo
+	case "object_id":
+		id := float64(s.ObjectID)
+		return NewColor(id, id, id)
+	case "shadow":
+		if s.Shadowed {
+			return NewColor(1, 1, 1)
+		}
+	}
+	return NewColor(0, 0, 0)
+}
+
+func isAOVName(name string) bool {
+	for _, n := range AOVNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AOVBuffers holds a canvas of raw values per AOV, the same size as the
+// colour canvas it was rendered with.
+type AOVBuffers map[string]Canvas
+
+// aovSample is the one ray per pixel the AOVs come from: through the pixel
+// centre, halfway through the shutter. Values such as object IDs cannot be
+// averaged over samples, so the buffers are not antialiased.
+var aovSample = CameraSample{X: 0.5, Y: 0.5, Time: 0.5}
+
+func (c *Camera) aovAt(w World, px, py int64) AOVSample {
+	if !c.InView(px, py, aovSample) {
+		return AOVSample{}
+	}
+	return w.AOVAt(c.RayForSample(px, py, aovSample))
+}
+
+// RenderWithAOVs renders like Render, also filling a buffer for each of
+// names, which must be in AOVNames.
+func (c *Camera) RenderWithAOVs(w World, names ...string) (Canvas, AOVBuffers, error) {
+	crop := c.CropWindow()
+	image := c.newImage()
+	buffers := AOVBuffers{}
+	for _, name := range names {
+		if !isAOVName(name) {
+			return image, nil, fmt.Errorf("unknown AOV %q", name)
+		}
+		buffers[name] = NewCanvas(image.Width, image.Height)
+	}
+	if c.Stats != nil {
+		c.Stats.Reset()
+	}
+	put := image.putAt(crop.X, crop.Y)
+	c.traceTilesContext(context.Background(), w, c.Tiles(), func(x, y int64, col Color, alpha float64) {
+		put(x, y, col, alpha)
+		if len(buffers) == 0 {
+			return
+		}
+		s := c.aovAt(w, x, y)
+		for name, b := range buffers {
+			b.WritePixel(int(x-crop.X), int(y-crop.Y), s.Color(name))
+		}
+	}, nil)
+	return image, buffers, nil
+}
+
+// Image turns the named buffer into something to look at: depth runs from
+// white at the nearest hit to dark grey at the furthest, normals are mapped
+// from [-1, 1] into [0, 1] and each object ID gets its own colour.
+func (b AOVBuffers) Image(name string) (Canvas, error) {
+	raw, ok := b[name]
+	if !ok {
+		return Canvas{}, fmt.Errorf("no %q AOV was rendered", name)
+	}
+	out := NewCanvas(raw.Width, raw.Height)
+	switch name {
+	case "depth":
+		near, far := math.Inf(1), 0.0
+		for _, col := range raw.Pixels {
+			if col.Red > 0 {
+				near = math.Min(near, col.Red)
+				far = math.Max(far, col.Red)
+			}
+		}
+		for i, col := range raw.Pixels {
+			if col.Red <= 0 {
+				continue
+			}
+			v := 1.0
+			if far > near {
+				v = 1 - 0.8*(col.Red-near)/(far-near)
+			}
+			out.Pixels[i] = NewColor(v, v, v)
+		}
+	case "normal":
+		for i, col := range raw.Pixels {
+			if col == (Color{}) {
+				continue
+			}
+			out.Pixels[i] = NewColor((col.Red+1)/2, (col.Green+1)/2, (col.Blue+1)/2)
+		}
+	case "object_id":
+		for i, col := range raw.Pixels {
+			if id := int(col.Red); id != 0 {
+				out.Pixels[i] = idColor(id)
+			}
+		}
+	default:
+		copy(out.Pixels, raw.Pixels)
+	}
+	return out, nil
+}
+
+// idColor scatters IDs around the hue circle so neighbouring IDs differ.
+func idColor(id int) Color {
+	const goldenAngle = 0.618033988749895
+	h := math.Mod(float64(id)*goldenAngle, 1) * 6
+	x := 1 - math.Abs(math.Mod(h, 2)-1)
+	switch int(h) {
+	case 0:
+		return NewColor(1, x, 0)
+	case 1:
+		return NewColor(x, 1, 0)
+	case 2:
+		return NewColor(0, 1, x)
+	case 3:
+		return NewColor(0, x, 1)
+	case 4:
+		return NewColor(x, 0, 1)
+	}
+	return NewColor(1, 0, x)
+}
+
+// Save writes the named buffer to path. PFM and HDR files get the raw
+// values; any other format gets the buffer's Image. HDR files clamp
+// negatives and keep eight bits of mantissa, so normals and object IDs
+// can only be saved raw as PFM. Only albedo is a colour: the other images
+// hold data for compositing and are written without the sRGB curve.
+func (b AOVBuffers) Save(name, path string) error {
+	raw, ok := b[name]
+	if !ok {
+		return fmt.Errorf("no %q AOV was rendered", name)
+	}
+	format, err := ImageFormat(path)
+	if err != nil {
+		return err
+	}
+	if format == "hdr" && (name == "normal" || name == "object_id") {
+		return fmt.Errorf("the %q AOV cannot be saved to %s: HDR files lose its values; use PFM", name, path)
+	}
+	if format == "pfm" || format == "hdr" {
+		return raw.Save(path)
+	}
+	img, err := b.Image(name)
+	if err != nil {
+		return err
+	}
+	opts := NewEncodeOptions()
+	opts.Display.SRGB = name == "albedo"
+	return img.SaveWith(path, opts)
+}
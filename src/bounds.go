@@ -13,12 +13,9 @@ type Bounds struct {
 
 type BoundingCube map[string]Tuple
 
-var boundCount = 0
-
 func NewBounds() *Bounds {
-	boundCount++
 	return &Bounds{
-		ID:      boundCount,
+		ID:      nextShapeID(),
 		Maximum: NewPoint(math.Inf(-1), math.Inf(-1), math.Inf(-1)),
 		Minimum: NewPoint(math.Inf(1), math.Inf(1), math.Inf(1)),
 	}
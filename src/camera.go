@@ -1,29 +1,107 @@
 package main
 
-import "math"
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const defaultTileSize = 16
 
 type Camera struct {
 	HSize       int64
 	VSize       int64
+	Projection  string
 	FieldOfView float64
+	ViewWidth   float64
+	ViewHeight  float64
 	Transform   Matrix
 	PixelSize   float64
 	HalfWidth   float64
 	HalfHeight  float64
+	Workers     int
+	TileSize    int64
+	Samples     int
+	SampleMode  string
+	Filter      Filter
+	Seed        int64
+	Crop        *Tile
+
+	CheckpointInterval time.Duration
+
+	Aperture      float64
+	FocalDistance float64
+	ShiftX        float64
+	ShiftY        float64
+	Distortion    *LensDistortion
+	FisheyeMap    string
+	ShutterOpen   float64
+	ShutterClose  float64
+
+	AdaptiveThreshold float64
+	AdaptiveDepth     int
+	Stats             *RenderStats
+
+	// Alpha makes Render, RenderInto and RenderWithAOVs fill the canvas's
+	// alpha with each pixel's coverage
+	Alpha bool
+}
+
+// Tile is a rectangle of pixels rendered as a single unit of work.
+type Tile struct {
+	X      int64
+	Y      int64
+	Width  int64
+	Height int64
 }
 
 func NewCamera(h, v int64, f float64) Camera {
 	bob := Camera{
 		HSize:       h,
 		VSize:       v,
+		Projection:  "perspective",
 		FieldOfView: f,
 		Transform:   IdentityMatrix(),
+		Workers:     runtime.NumCPU(),
+		TileSize:    defaultTileSize,
+		Samples:     1,
+		SampleMode:  "grid",
+		Filter:      NewBoxFilter(),
+
+		CheckpointInterval: 10 * time.Second,
+
+		Aperture:      0,
+		FocalDistance: 1,
+
+		AdaptiveThreshold: 0.1,
+		AdaptiveDepth:     2,
+		Stats:             &RenderStats{},
 	}
 	bob.CalcPixelSize()
 	return bob
 }
 
+// NewOrthographicCamera looks along parallel rays at a window viewWidth by
+// viewHeight world units across. If the window's shape differs from the
+// image's, the window is widened to fit rather than cropped.
+func NewOrthographicCamera(h, v int64, viewWidth, viewHeight float64) Camera {
+	bob := NewCamera(h, v, 0)
+	bob.Projection = "orthographic"
+	bob.ViewWidth = viewWidth
+	bob.ViewHeight = viewHeight
+	bob.CalcPixelSize()
+	return bob
+}
+
 func (c *Camera) CalcPixelSize() {
+	if c.Projection == "orthographic" {
+		c.PixelSize = math.Max(c.ViewWidth/float64(c.HSize), c.ViewHeight/float64(c.VSize))
+		c.HalfWidth = c.PixelSize * float64(c.HSize) / 2
+		c.HalfHeight = c.PixelSize * float64(c.VSize) / 2
+		return
+	}
 	halfView := math.Tan(c.FieldOfView / 2)
 	aspect := float64(c.HSize) / float64(c.VSize)
 	if aspect > 1 {
@@ -38,33 +116,244 @@ func (c *Camera) CalcPixelSize() {
 }
 
 func (c *Camera) RayForPixel(px, py int64) Ray {
-	xoffset := (float64(px) + 0.5) * c.PixelSize
-	yoffset := (float64(py) + 0.5) * c.PixelSize
-	worldX := c.HalfWidth - xoffset
-	worldY := c.HalfHeight - yoffset
+	return c.RayForSample(px, py, pixelCentre)
+}
 
+func (c *Camera) RayForSample(px, py int64, s CameraSample) Ray {
+	origin, direction := c.localRay(px, py, s)
 	m := c.Transform.Inverse()
-	pixel := m.MultiplyTuple(NewPoint(worldX, worldY, -1))
-	origin := m.MultiplyTuple(NewPoint(0, 0, 0))
-	direction := pixel.Subtract(origin).Normalize()
+	time := c.ShutterOpen + s.Time*(c.ShutterClose-c.ShutterOpen)
+	return NewRayAt(m.MultiplyTuple(origin), m.MultiplyTuple(direction).Normalize(), time)
+}
+
+// localRay is the ray for the sample in camera space, before Transform.
+func (c *Camera) localRay(px, py int64, s CameraSample) (Tuple, Tuple) {
+	switch c.Projection {
+	case "equirectangular":
+		return NewPoint(0, 0, 0), c.equirectangularDirection(px, py, s)
+	case "fisheye":
+		direction, _ := c.fisheyeDirection(px, py, s)
+		return NewPoint(0, 0, 0), direction
+	}
 
-	return NewRay(origin, direction)
+	xoffset := (float64(px) + s.X) * c.PixelSize
+	yoffset := (float64(py) + s.Y) * c.PixelSize
+	// Shifting slides the image window sideways without turning the camera
+	worldX := c.HalfWidth - xoffset + c.ShiftX
+	worldY := c.HalfHeight - yoffset + c.ShiftY
+
+	if c.Projection == "orthographic" {
+		return NewPoint(worldX, worldY, 0), NewVector(0, 0, -1)
+	}
+	if c.Distortion != nil {
+		worldX, worldY = c.Distortion.Undistort(worldX, worldY)
+	}
+	if c.Aperture > 0 {
+		return c.thinLensRay(worldX, worldY, s)
+	}
+	return NewPoint(0, 0, 0), NewVector(worldX, worldY, -1)
+}
+
+// InView reports whether the sample falls on the part of the image the
+// projection covers; only the fisheye's image circle leaves pixels uncovered.
+func (c *Camera) InView(px, py int64, s CameraSample) bool {
+	if c.Projection == "fisheye" {
+		_, ok := c.fisheyeDirection(px, py, s)
+		return ok
+	}
+	return true
+}
+
+// thinLensRay starts the ray at the sample's point on the lens and aims it at
+// where the pinhole ray through the same pixel meets the focal plane, so only
+// objects at FocalDistance are sharp.
+func (c *Camera) thinLensRay(worldX, worldY float64, s CameraSample) (Tuple, Tuple) {
+	f := c.FocalDistance
+	focus := NewPoint(worldX*f, worldY*f, -f)
+	origin := NewPoint(s.LensX*c.Aperture, s.LensY*c.Aperture, 0)
+	return origin, focus.Subtract(origin)
 }
 
 func (c *Camera) SetTransform(t Matrix) {
 	c.Transform = t
 }
 
-func (c *Camera) Render(w World) Canvas {
-	image := NewCanvas(int(c.HSize), int(c.VSize))
-
-	var y, x int64
-	for y = 0; y < c.VSize; y++ {
-		for x = 0; x < c.HSize; x++ {
-			ray := c.RayForPixel(x, y)
-			color := w.ColorAt(ray, maxReflects)
-			image.WritePixel(int(x), int(y), color)
+// CropWindow is the part of the frame Render traces: Crop clipped to the
+// image, or the whole image when Crop is nil.
+func (c *Camera) CropWindow() Tile {
+	full := Tile{X: 0, Y: 0, Width: c.HSize, Height: c.VSize}
+	if c.Crop == nil {
+		return full
+	}
+	t := *c.Crop
+	if t.X < 0 {
+		t.Width += t.X
+		t.X = 0
+	}
+	if t.Y < 0 {
+		t.Height += t.Y
+		t.Y = 0
+	}
+	if t.X+t.Width > c.HSize {
+		t.Width = c.HSize - t.X
+	}
+	if t.Y+t.Height > c.VSize {
+		t.Height = c.VSize - t.Y
+	}
+	if t.Width < 0 || t.Height < 0 {
+		t.Width, t.Height = 0, 0
+	}
+	return t
+}
+
+// Tiles splits the crop window into TileSize squares in row-major order; tiles
+// along the right and bottom edges are clipped to the window.
+func (c *Camera) Tiles() []Tile {
+	size := c.TileSize
+	if size < 1 {
+		size = defaultTileSize
+	}
+	crop := c.CropWindow()
+	tiles := []Tile{}
+	for y := crop.Y; y < crop.Y+crop.Height; y += size {
+		for x := crop.X; x < crop.X+crop.Width; x += size {
+			t := Tile{X: x, Y: y, Width: size, Height: size}
+			if x+size > crop.X+crop.Width {
+				t.Width = crop.X + crop.Width - x
+			}
+			if y+size > crop.Y+crop.Height {
+				t.Height = crop.Y + crop.Height - y
+			}
+			tiles = append(tiles, t)
 		}
 	}
+	return tiles
+}
+
+func (c *Camera) RenderTile(w World, t Tile, image *Canvas) {
+	c.traceTile(context.Background(), w, t, nil, image.putAt(0, 0))
+}
+
+// traceTile hands every pixel colour in the tile, and its coverage, by its
+// position in the full frame, to put. It gives up between rows once ctx is cancelled, and
+// reports whether the whole tile was traced. corners is only used in
+// adaptive mode, and may be nil.
+func (c *Camera) traceTile(ctx context.Context, w World, t Tile, corners *adaptiveCorners, put func(x, y int64, col Color, alpha float64)) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if c.SampleMode == "adaptive" {
+		c.renderAdaptiveTile(w, t, corners, put)
+		return true
+	}
+	for y := t.Y; y < t.Y+t.Height; y++ {
+		if ctx.Err() != nil {
+			return false
+		}
+		for x := t.X; x < t.X+t.Width; x++ {
+			col, alpha := c.PixelColorCoverage(w, x, y)
+			put(x, y, col, alpha)
+		}
+	}
+	return true
+}
+
+// RenderTiles traces the given tiles into image on a pool of Workers
+// goroutines. Every pixel is traced independently, so the result does not
+// depend on the number of workers or the order tiles are picked up in.
+func (c *Camera) RenderTiles(w World, tiles []Tile, image *Canvas) {
+	if c.Alpha {
+		image.AddAlpha()
+	}
+	c.traceTiles(w, tiles, image.putAt(0, 0))
+}
+
+// newImage is a canvas for the crop window, with alpha when the camera
+// records it.
+func (c *Camera) newImage() Canvas {
+	crop := c.CropWindow()
+	image := NewCanvas(int(crop.Width), int(crop.Height))
+	if c.Alpha {
+		image.AddAlpha()
+	}
 	return image
 }
+
+// putAt writes traced pixels into the canvas, shifted so the frame's
+// (x0, y0) lands on its top left corner. The alpha is dropped if the canvas
+// has none.
+func (c *Canvas) putAt(x0, y0 int64) func(x, y int64, col Color, alpha float64) {
+	return func(x, y int64, col Color, alpha float64) {
+		px, py := int(x-x0), int(y-y0)
+		c.WritePixel(px, py, col)
+		c.WriteAlpha(px, py, alpha)
+	}
+}
+
+func (c *Camera) traceTiles(w World, tiles []Tile, put func(x, y int64, col Color, alpha float64)) {
+	c.traceTilesContext(context.Background(), w, tiles, put, nil)
+}
+
+// traceTilesContext is traceTiles, calling done, if given, from the worker
+// as each tile is finished. Once ctx is cancelled no more tiles are started,
+// tiles in flight stop at the end of their current row, and ctx's error is
+// returned.
+func (c *Camera) traceTilesContext(ctx context.Context, w World, tiles []Tile, put func(x, y int64, col Color, alpha float64), done func(t Tile)) error {
+	workers := c.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tiles) {
+		workers = len(tiles)
+	}
+
+	var corners *adaptiveCorners
+	if c.SampleMode == "adaptive" {
+		corners = newAdaptiveCorners(tiles)
+	}
+	jobs := make(chan Tile)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				if c.traceTile(ctx, w, t, corners, put) && done != nil {
+					done(t)
+				}
+			}
+		}()
+	}
+feed:
+	for _, t := range tiles {
+		select {
+		case jobs <- t:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Render traces the crop window and returns a canvas of just that region,
+// with the window's top left corner at (0, 0).
+func (c *Camera) Render(w World) Canvas {
+	crop := c.CropWindow()
+	image := c.newImage()
+	if c.Stats != nil {
+		c.Stats.Reset()
+	}
+	c.traceTiles(w, c.Tiles(), image.putAt(crop.X, crop.Y))
+	return image
+}
+
+// RenderInto traces the crop window over the matching pixels of a full size
+// image, leaving the rest of it as it was.
+func (c *Camera) RenderInto(w World, image *Canvas) {
+	if c.Stats != nil {
+		c.Stats.Reset()
+	}
+	c.RenderTiles(w, c.Tiles(), image)
+}
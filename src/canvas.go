@@ -1,82 +1,174 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"math"
+	"strconv"
 	"strings"
 )
 
+// Canvas stores its pixels row by row in one slice. Copies of a Canvas share
+// the pixels, and goroutines may write distinct pixels at the same time.
 type Canvas struct {
 	Width  int
 	Height int
-	Pixels map[int]map[int]Color
+	Pixels []Color
+	// Alpha, when it is not nil, holds each pixel's coverage in the same
+	// order as Pixels; a canvas without it is opaque. Pixels are then
+	// premultiplied: a white surface covering half a pixel over nothing
+	// leaves it half grey with an alpha of 0.5.
+	Alpha []float64
 }
 
 func NewCanvas(w, h int) Canvas {
-	me := Canvas{
+	if w < 0 {
+		w = 0
+	}
+	if h < 0 {
+		h = 0
+	}
+	return Canvas{
 		Width:  w,
 		Height: h,
+		Pixels: make([]Color, w*h),
 	}
-	defaultColour := NewColor(0, 0, 0)
-	me.Pixels = make(map[int]map[int]Color, h)
-	for i := 0; i < w; i++ {
-		me.Pixels[i] = make(map[int]Color, w)
-		for j := 0; j < h; j++ {
-			me.Pixels[i][j] = defaultColour
-		}
-	}
-	return me
+}
+
+func (c *Canvas) inside(x, y int) bool {
+	return x >= 0 && x < c.Width && y >= 0 && y < c.Height
 }
 
 func (c *Canvas) WritePixel(x, y int, col Color) {
-	if x < 0 || x > c.Width || y < 0 || y > c.Height {
+	if !c.inside(x, y) {
 		return
 	}
-	c.Pixels[x][y] = col
+	c.Pixels[y*c.Width+x] = col
 }
 
+// PixelAt is black outside the canvas.
 func (c *Canvas) PixelAt(x, y int) Color {
-	return c.Pixels[x][y]
+	if !c.inside(x, y) {
+		return Color{}
+	}
+	return c.Pixels[y*c.Width+x]
+}
+
+// Row is row y of the canvas, sharing its pixels.
+func (c *Canvas) Row(y int) []Color {
+	return c.Pixels[y*c.Width : (y+1)*c.Width]
+}
+
+func (c *Canvas) HasAlpha() bool {
+	return c.Alpha != nil
 }
 
+// AddAlpha gives an opaque canvas an alpha channel, with every pixel fully
+// covered.
+func (c *Canvas) AddAlpha() {
+	if c.Alpha != nil {
+		return
+	}
+	c.Alpha = make([]float64, len(c.Pixels))
+	for i := range c.Alpha {
+		c.Alpha[i] = 1
+	}
+}
+
+// AlphaAt is 1 everywhere on a canvas without alpha, and 0 outside any
+// canvas.
+func (c *Canvas) AlphaAt(x, y int) float64 {
+	if !c.inside(x, y) {
+		return 0
+	}
+	if c.Alpha == nil {
+		return 1
+	}
+	return c.Alpha[y*c.Width+x]
+}
+
+// WriteAlpha does nothing to a canvas without alpha; call AddAlpha first.
+func (c *Canvas) WriteAlpha(x, y int, a float64) {
+	if !c.inside(x, y) || c.Alpha == nil {
+		return
+	}
+	c.Alpha[y*c.Width+x] = a
+}
+
+// Region copies the w by h rectangle with its top left corner at (x, y).
+// Parts of the rectangle outside the canvas are black, and transparent if
+// the canvas has alpha.
+func (c *Canvas) Region(x, y, w, h int) Canvas {
+	out := NewCanvas(w, h)
+	if c.HasAlpha() {
+		out.AddAlpha()
+	}
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			out.WritePixel(i, j, c.PixelAt(x+i, y+j))
+			out.WriteAlpha(i, j, c.AlphaAt(x+i, y+j))
+		}
+	}
+	return out
+}
+
+// SetRegion copies src onto the canvas with its top left corner at (x, y),
+// clipping whatever falls outside. Alpha is copied when the canvas has it.
+func (c *Canvas) SetRegion(x, y int, src Canvas) {
+	for j := 0; j < src.Height; j++ {
+		for i := 0; i < src.Width; i++ {
+			c.WritePixel(x+i, y+j, src.PixelAt(i, j))
+			c.WriteAlpha(x+i, y+j, src.AlphaAt(i, j))
+		}
+	}
+}
+
+// ToPPM is the canvas as a plain PPM, with its colours as they are.
 func (c *Canvas) ToPPM() string {
+	out := strings.Builder{}
+	c.WritePPM(&out)
+	return out.String()
+}
+
+// WritePPM streams the canvas's colours as they are, one row at a time,
+// with no line longer than 70 characters. Save and SaveWith run the display
+// transform first.
+func (c *Canvas) WritePPM(w io.Writer) error {
 	colorDepth := 255
-	body := []string{}
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "P3\n%d %d\n%d\n", c.Width, c.Height, colorDepth)
+	row := []byte{}
 	for y := 0; y < c.Height; y++ {
-		newRow := ""
-		for x := 0; x < c.Width; x++ {
-			cell := c.Pixels[x][y]
-			red := colorToDepth(cell.Red, colorDepth)
-			green := colorToDepth(cell.Green, colorDepth)
-			blue := colorToDepth(cell.Blue, colorDepth)
-			asString := fmt.Sprintf("%d %d %d ", red, green, blue)
-			newRow = newRow + asString
-		}
-		body = append(body, strings.Trim(newRow, " "))
-	}
-	toReturn := ""
-	for _, row := range body {
-	inner:
-		for {
-			if len(row) < 71 {
-				toReturn = toReturn + row + "\n"
-				break inner
-			}
-
-			splitCol := 69
-		checker:
-			for {
-				if row[splitCol:splitCol+1] == " " {
-					toReturn = toReturn + row[0:splitCol] + "\n"
-					row = row[splitCol+1:]
-					break checker
+		row = row[:0]
+		for _, cell := range c.Row(y) {
+			for _, v := range []float64{cell.Red, cell.Green, cell.Blue} {
+				if len(row) > 0 {
+					row = append(row, ' ')
 				}
-				splitCol = splitCol - 1
+				row = strconv.AppendInt(row, int64(colorToDepth(v, colorDepth)), 10)
 			}
 		}
+		writeWrapped(out, row)
 	}
+	out.WriteString("\n")
+	return out.Flush()
+}
 
-	return fmt.Sprintf("P3\n%d %d\n%d\n%s\n", c.Width, c.Height, colorDepth, toReturn)
+// writeWrapped breaks a row at the last space that keeps each line under 70
+// characters; the final piece may use all 70.
+func writeWrapped(out *bufio.Writer, row []byte) {
+	for len(row) >= 71 {
+		splitCol := 69
+		for row[splitCol] != ' ' {
+			splitCol--
+		}
+		out.Write(row[:splitCol])
+		out.WriteByte('\n')
+		row = row[splitCol+1:]
+	}
+	out.Write(row)
+	out.WriteByte('\n')
 }
 
 func colorToDepth(code float64, depth int) int {
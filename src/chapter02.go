@@ -7,6 +7,22 @@ import (
 )
 
 func ChapterTwo() {
+	c, x := chapterTwoTrajectory(func() { fmt.Print(".") })
+	tempFile := filepath.Join(os.TempDir(), "chapter02.ppm")
+	saveChapter(&c, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+	fmt.Printf("%d iterations\n", x)
+}
+
+// ChapterTwoCanvas plots the chapter's projectile on a 900 by 550 canvas.
+func ChapterTwoCanvas() Canvas {
+	c, _ := chapterTwoTrajectory(func() {})
+	return c
+}
+
+// chapterTwoTrajectory plots the projectile, calling onTick for every step
+// above ground, and returns the canvas with the number of steps taken.
+func chapterTwoTrajectory(onTick func()) (Canvas, int) {
 	type Projectile struct {
 		Position Tuple
 		Velocity Tuple
@@ -41,10 +57,7 @@ func ChapterTwo() {
 		if p.Position.Y <= 0 {
 			break
 		}
-		fmt.Print(".")
+		onTick()
 	}
-	tempFile := filepath.Join(os.TempDir(), "chapter02.ppm")
-	os.WriteFile(tempFile, []byte(c.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
-	fmt.Printf("%d iterations\n", x)
+	return c, x
 }
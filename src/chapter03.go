@@ -8,6 +8,14 @@ import (
 )
 
 func ChapterThree() {
+	c := ChapterThreeCanvas()
+	tempFile := filepath.Join(os.TempDir(), "chapter03.ppm")
+	saveChapter(&c, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
+
+// ChapterThreeCanvas draws the chapter's clock face on a 100 by 100 canvas.
+func ChapterThreeCanvas() Canvas {
 	c := NewCanvas(100, 100)
 	segs := math.Pi / 6
 	white := NewColor(1, 1, 1)
@@ -19,7 +27,5 @@ func ChapterThree() {
 		me.Z *= 40
 		c.WritePixel(50+int(me.X), 50+int(me.Z), white)
 	}
-	tempFile := filepath.Join(os.TempDir(), "chapter03.ppm")
-	os.WriteFile(tempFile, []byte(c.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return c
 }
@@ -7,11 +7,20 @@ import (
 )
 
 func ChapterFive() {
+	canvas := ChapterFiveCanvas(200)
+	tempFile := filepath.Join(os.TempDir(), "chapter05.ppm")
+	saveChapter(&canvas, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
+
+// ChapterFiveCanvas draws the silhouette of the chapter's sphere on a square
+// canvas size pixels across.
+func ChapterFiveCanvas(size int) Canvas {
 	rayOrigin := NewPoint(0, 0, -5)
 	wallZ := 10.0
 	wallSize := 7.0
 
-	canvasPixels := 200.0
+	canvasPixels := float64(size)
 	pixelSize := wallSize / canvasPixels
 	half := wallSize / 2
 
@@ -34,7 +43,5 @@ func ChapterFive() {
 		}
 	}
 
-	tempFile := filepath.Join(os.TempDir(), "chapter05.ppm")
-	os.WriteFile(tempFile, []byte(canvas.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return canvas
 }
@@ -7,11 +7,20 @@ import (
 )
 
 func ChapterSix() {
+	canvas := ChapterSixCanvas(200)
+	tempFile := filepath.Join(os.TempDir(), "chapter06.ppm")
+	saveChapter(&canvas, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
+
+// ChapterSixCanvas draws the chapter's lit sphere on a square canvas size
+// pixels across.
+func ChapterSixCanvas(size int) Canvas {
 	rayOrigin := NewPoint(0, 0, -5)
 	wallZ := 10.0
 	wallSize := 7.0
 
-	canvasPixels := 200.0
+	canvasPixels := float64(size)
 	pixelSize := wallSize / canvasPixels
 	half := wallSize / 2
 
@@ -44,7 +53,5 @@ func ChapterSix() {
 		}
 	}
 
-	tempFile := filepath.Join(os.TempDir(), "chapter06.ppm")
-	os.WriteFile(tempFile, []byte(canvas.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return canvas
 }
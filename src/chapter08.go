@@ -8,7 +8,17 @@ import (
 )
 
 func ChapterEight() {
+	world, camera := ChapterEightScene(600, 300)
+	canvas := camera.Render(world)
+
+	tempFile := filepath.Join(os.TempDir(), "chapter08.ppm")
+	saveChapter(&canvas, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
 
+// ChapterEightScene builds the chapter's world and a camera hsize by vsize
+// pixels.
+func ChapterEightScene(hsize, vsize int64) (World, Camera) {
 	floor := NewSphere()
 	floor.SetTransform(NewScaling(10, 0.01, 10))
 	floor.SetMaterial(NewMaterial())
@@ -62,12 +72,7 @@ func ChapterEight() {
 	world.Objects = append(world.Objects, rightWall)
 	world.Objects = append(world.Objects, leftWall)
 	world.Objects = append(world.Objects, floor)
-	camera := NewCamera(600, 300, math.Pi/3)
+	camera := NewCamera(hsize, vsize, math.Pi/3)
 	camera.SetTransform(ViewTransform(NewPoint(0, 1.5, -5), NewPoint(0, 1, 0), NewVector(0, 1, 0)))
-
-	canvas := camera.Render(world)
-
-	tempFile := filepath.Join(os.TempDir(), "chapter08.ppm")
-	os.WriteFile(tempFile, []byte(canvas.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return world, camera
 }
@@ -8,7 +8,17 @@ import (
 )
 
 func ChapterNine() {
+	world, camera := ChapterNineScene(600, 300)
+	canvas := camera.Render(world)
+
+	tempFile := filepath.Join(os.TempDir(), "chapter09a.ppm")
+	saveChapter(&canvas, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
 
+// ChapterNineScene builds the chapter's world and a camera hsize by vsize
+// pixels.
+func ChapterNineScene(hsize, vsize int64) (World, Camera) {
 	floor := NewPlane()
 	floor.SetTransform(NewScaling(10, 0.01, 10))
 	floor.SetMaterial(NewMaterial())
@@ -49,12 +59,7 @@ func ChapterNine() {
 	world.Objects = append(world.Objects, middle)
 	world.Objects = append(world.Objects, right)
 	world.Objects = append(world.Objects, floor)
-	camera := NewCamera(600, 300, math.Pi/3)
+	camera := NewCamera(hsize, vsize, math.Pi/3)
 	camera.SetTransform(ViewTransform(NewPoint(0, 1.5, -5), NewPoint(0, 1, 0), NewVector(0, 1, 0)))
-
-	canvas := camera.Render(world)
-
-	tempFile := filepath.Join(os.TempDir(), "chapter09a.ppm")
-	os.WriteFile(tempFile, []byte(canvas.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return world, camera
 }
@@ -8,7 +8,17 @@ import (
 )
 
 func ChapterFourteen() {
+	world, camera := ChapterFourteenScene(600, 300)
+	canvas := camera.Render(world)
+
+	tempFile := filepath.Join(os.TempDir(), "chapter14.ppm")
+	saveChapter(&canvas, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
 
+// ChapterFourteenScene builds the chapter's world and a camera hsize by vsize
+// pixels.
+func ChapterFourteenScene(hsize, vsize int64) (World, Camera) {
 	hexagonCorner := func() Shaper {
 		corner := NewSphere()
 		b := NewTranslation(0, 0, -1)
@@ -59,12 +69,7 @@ func ChapterFourteen() {
 	world := NewWorld()
 	world.SetLight(NewLight(NewPoint(-10, 10, -10), NewColor(1, 1, 1)))
 	world.Objects = append(world.Objects, bob)
-	camera := NewCamera(600, 300, math.Pi/3)
+	camera := NewCamera(hsize, vsize, math.Pi/3)
 	camera.SetTransform(ViewTransform(NewPoint(0, 1.5, -5), NewPoint(0, 1, 0), NewVector(0, 1, 0)))
-
-	canvas := camera.Render(world)
-
-	tempFile := filepath.Join(os.TempDir(), "chapter14.ppm")
-	os.WriteFile(tempFile, []byte(canvas.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return world, camera
 }
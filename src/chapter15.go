@@ -8,20 +8,25 @@ import (
 )
 
 func ChapterFifteen() {
+	world, camera := ChapterFifteenScene(200, 100, `/private/tmp/go-raytrace/src/fixtures/teapot.obj`)
+	canvas := camera.Render(world)
+
+	tempFile := filepath.Join(os.TempDir(), "chapter15.ppm")
+	saveChapter(&canvas, tempFile)
+	fmt.Printf("Open %s\n", tempFile)
+}
 
-	p := NewParserFromFile(`/private/tmp/go-raytrace/src/fixtures/teapot.obj`)
+// ChapterFifteenScene builds the chapter's world, with the teapot read from
+// the OBJ file at teapot, and a camera hsize by vsize pixels.
+func ChapterFifteenScene(hsize, vsize int64, teapot string) (World, Camera) {
+	p := NewParserFromFile(teapot)
 
 	world := NewWorld()
 	world.SetLight(NewLight(NewPoint(-10, 10, -10), NewColor(1, 1, 1)))
 	g := p.ToGroup()
 	g.SetTransform(NewRotationY(math.Pi / 4))
 	world.Objects = append(world.Objects, g)
-	camera := NewCamera(200, 100, math.Pi/3)
+	camera := NewCamera(hsize, vsize, math.Pi/3)
 	camera.SetTransform(ViewTransform(NewPoint(0, 1.5, -3.1), NewPoint(0, 1, 0), NewVector(0, 1, -5)))
-
-	canvas := camera.Render(world)
-
-	tempFile := filepath.Join(os.TempDir(), "chapter15.ppm")
-	os.WriteFile(tempFile, []byte(canvas.ToPPM()), 0666)
-	fmt.Printf("Open %s\n", tempFile)
+	return world, camera
 }
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointMismatch is returned when a checkpoint was written for a
+// different scene or camera.
+var ErrCheckpointMismatch = errors.New("checkpoint is for a different scene or camera")
+
+const checkpointHeader = "raytrace checkpoint"
+
+// Checkpoint holds the tiles already traced for one scene and camera, keyed
+// by hash so it cannot be resumed against anything else.
+type Checkpoint struct {
+	Hash  string
+	Tiles map[Tile][]Color
+	// Alpha holds the coverage of the tiles of a camera with Alpha set
+	Alpha map[Tile][]float64
+}
+
+func newCheckpoint(sceneHash string) *Checkpoint {
+	return &Checkpoint{Hash: sceneHash, Tiles: map[Tile][]Color{}, Alpha: map[Tile][]float64{}}
+}
+
+// SceneHash identifies everything that changes the colour of a pixel: the
+// lights, the shapes and the camera. Settings that only change how the work
+// is scheduled, such as Workers, TileSize and Crop, are left out.
+func SceneHash(w World, c Camera) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "camera %d %d %s %v %v %v\n", c.HSize, c.VSize, c.Projection, c.FieldOfView, c.ViewWidth, c.ViewHeight)
+	hashMatrix(h, c.Transform)
+	fmt.Fprintf(h, "samples %d %s %+v %d\n", c.Samples, c.SampleMode, c.Filter, c.Seed)
+	fmt.Fprintf(h, "lens %v %v %v %v %+v %s\n", c.Aperture, c.FocalDistance, c.ShiftX, c.ShiftY, c.Distortion, c.FisheyeMap)
+	fmt.Fprintf(h, "shutter %v %v\n", c.ShutterOpen, c.ShutterClose)
+	fmt.Fprintf(h, "adaptive %v %d\n", c.AdaptiveThreshold, c.AdaptiveDepth)
+	fmt.Fprintf(h, "alpha %v\n", c.Alpha)
+	for _, l := range w.Lights {
+		fmt.Fprintf(h, "light %v %v\n", l.Position, l.Intensity)
+	}
+	for _, o := range w.Objects {
+		hashShape(h, o)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func hashMatrix(h hash.Hash, m Matrix) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			fmt.Fprintf(h, "%v ", m.Cells[i][j])
+		}
+	}
+	fmt.Fprintln(h)
+}
+
+func hashShape(h hash.Hash, s Shaper) {
+	fmt.Fprintf(h, "shape %s\n", s.GetType())
+	hashMatrix(h, s.GetTransform())
+	if m, ok := s.(Mover); ok && m.IsMoving() {
+		hashMatrix(h, m.GetEndTransform())
+	}
+	m := s.GetMaterial()
+	fmt.Fprintf(h, "material %v %v %v %v %v %v %v %v\n", m.Color, m.Ambient, m.Diffuse, m.Specular, m.Shininess, m.Reflective, m.Transparency, m.RefractiveIndex)
+	if m.HasPattern {
+		fmt.Fprintf(h, "pattern %+v\n", m.Pattern)
+	}
+	switch t := s.(type) {
+	case *Cylinder:
+		fmt.Fprintf(h, "%v %v %v\n", t.Minimum, t.Maximum, t.Closed)
+	case *Cone:
+		fmt.Fprintf(h, "%v %v %v\n", t.Minimum, t.Maximum, t.Closed)
+	case *Triangle:
+		fmt.Fprintf(h, "%v %v %v\n", t.P1, t.P2, t.P3)
+	case *Group:
+		for _, child := range t.GetShapes() {
+			hashShape(h, child)
+		}
+		fmt.Fprintln(h, "end group")
+	}
+}
+
+// LoadCheckpoint reads the tiles saved in path. A missing file is an empty
+// checkpoint; a file written for another hash is ErrCheckpointMismatch.
+// A tile cut short by the process dying is dropped and traced again.
+func LoadCheckpoint(path, sceneHash string) (*Checkpoint, error) {
+	cp := newCheckpoint(sceneHash)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	if !scanner.Scan() || scanner.Text() != checkpointHeader {
+		return nil, fmt.Errorf("%s is not a checkpoint", path)
+	}
+	if !scanner.Scan() || scanner.Text() != sceneHash {
+		return nil, ErrCheckpointMismatch
+	}
+	for scanner.Scan() {
+		t, colors, alpha, ok := parseCheckpointTile(scanner.Text())
+		if ok {
+			cp.Tiles[t] = colors
+			if alpha != nil {
+				cp.Alpha[t] = alpha
+			}
+		}
+	}
+	return cp, scanner.Err()
+}
+
+// parseCheckpointTile reads a tile's pixels, which have an alpha after
+// their colour when the tile has four numbers a pixel.
+func parseCheckpointTile(line string) (Tile, []Color, []float64, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "tile" {
+		return Tile{}, nil, nil, false
+	}
+	var rect [4]int64
+	for i := range rect {
+		v, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return Tile{}, nil, nil, false
+		}
+		rect[i] = v
+	}
+	t := Tile{X: rect[0], Y: rect[1], Width: rect[2], Height: rect[3]}
+	values := fields[5:]
+	n := t.Width * t.Height
+	stride := int64(3)
+	if n > 0 && int64(len(values)) == n*4 {
+		stride = 4
+	}
+	if int64(len(values)) != n*stride {
+		return Tile{}, nil, nil, false
+	}
+	colors := make([]Color, n)
+	var alpha []float64
+	if stride == 4 {
+		alpha = make([]float64, n)
+	}
+	for i := range colors {
+		var rgba [4]float64
+		for j := int64(0); j < stride; j++ {
+			v, err := strconv.ParseFloat(values[int64(i)*stride+j], 64)
+			if err != nil {
+				return Tile{}, nil, nil, false
+			}
+			rgba[j] = v
+		}
+		colors[i] = NewColor(rgba[0], rgba[1], rgba[2])
+		if alpha != nil {
+			alpha[i] = rgba[3]
+		}
+	}
+	return t, colors, alpha, true
+}
+
+// writeCheckpointTile writes alpha after each colour when it is not nil.
+func writeCheckpointTile(out io.Writer, t Tile, colors []Color, alpha []float64) error {
+	line := []string{"tile", fmt.Sprint(t.X), fmt.Sprint(t.Y), fmt.Sprint(t.Width), fmt.Sprint(t.Height)}
+	for i, col := range colors {
+		line = append(line,
+			strconv.FormatFloat(col.Red, 'g', -1, 64),
+			strconv.FormatFloat(col.Green, 'g', -1, 64),
+			strconv.FormatFloat(col.Blue, 'g', -1, 64),
+		)
+		if alpha != nil {
+			line = append(line, strconv.FormatFloat(alpha[i], 'g', -1, 64))
+		}
+	}
+	_, err := fmt.Fprintln(out, strings.Join(line, " "))
+	return err
+}
+
+// RenderWithCheckpoint renders like Render, saving finished tiles to the
+// sidecar file at path every CheckpointInterval. Tiles already in a matching
+// checkpoint are copied rather than traced; a checkpoint for a different
+// scene or camera is thrown away and the render starts again.
+func (c *Camera) RenderWithCheckpoint(w World, path string) (Canvas, error) {
+	crop := c.CropWindow()
+	image := c.newImage()
+	if c.Stats != nil {
+		c.Stats.Reset()
+	}
+
+	sceneHash := SceneHash(w, *c)
+	cp, err := LoadCheckpoint(path, sceneHash)
+	if errors.Is(err, ErrCheckpointMismatch) {
+		cp, err = newCheckpoint(sceneHash), nil
+	}
+	if err != nil {
+		return image, err
+	}
+
+	// Rewrite the file with just the tiles being kept, so a rejected or
+	// truncated checkpoint never lingers in it. The rewrite goes to a
+	// temporary file renamed over the old one, so a crash part way through
+	// still leaves the old checkpoint.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return image, err
+	}
+	defer os.Remove(tmp.Name())
+	out := bufio.NewWriter(tmp)
+	fmt.Fprintln(out, checkpointHeader)
+	fmt.Fprintln(out, sceneHash)
+
+	todo := []Tile{}
+	for _, t := range c.Tiles() {
+		colors, ok := cp.Tiles[t]
+		alpha, hasAlpha := cp.Alpha[t]
+		if !ok || hasAlpha != c.Alpha {
+			todo = append(todo, t)
+			continue
+		}
+		for i, col := range colors {
+			x, y := int(t.X-crop.X)+i%int(t.Width), int(t.Y-crop.Y)+i/int(t.Width)
+			image.WritePixel(x, y, col)
+			if hasAlpha {
+				image.WriteAlpha(x, y, alpha[i])
+			}
+		}
+		if err := writeCheckpointTile(out, t, colors, alpha); err != nil {
+			tmp.Close()
+			return image, err
+		}
+	}
+	err = out.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return image, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return image, err
+	}
+	defer f.Close()
+	out = bufio.NewWriter(f)
+
+	interval := c.CheckpointInterval
+	var lock sync.Mutex
+	var saveErr error
+	lastSave := time.Now()
+	c.traceTilesContext(context.Background(), w, todo, image.putAt(crop.X, crop.Y), func(t Tile) {
+		colors := make([]Color, 0, t.Width*t.Height)
+		var alpha []float64
+		if c.Alpha {
+			alpha = make([]float64, 0, t.Width*t.Height)
+		}
+		for y := t.Y; y < t.Y+t.Height; y++ {
+			for x := t.X; x < t.X+t.Width; x++ {
+				colors = append(colors, image.PixelAt(int(x-crop.X), int(y-crop.Y)))
+				if c.Alpha {
+					alpha = append(alpha, image.AlphaAt(int(x-crop.X), int(y-crop.Y)))
+				}
+			}
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if saveErr != nil {
+			return
+		}
+		saveErr = writeCheckpointTile(out, t, colors, alpha)
+		if saveErr == nil && time.Since(lastSave) >= interval {
+			saveErr = out.Flush()
+			lastSave = time.Now()
+		}
+	})
+	if saveErr != nil {
+		return image, saveErr
+	}
+	return image, out.Flush()
+}
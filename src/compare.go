@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ImageDiff measures how far one canvas is from another. Errors are the
+// absolute differences of single channels; PSNR and SSIM treat 1 as white.
+// Identical canvases have a PSNR of +Inf and an SSIM of 1.
+type ImageDiff struct {
+	MaxError  float64
+	MeanError float64
+	PSNR      float64
+	SSIM      float64
+}
+
+func (d ImageDiff) String() string {
+	return fmt.Sprintf("max error %.5f, mean error %.5f, PSNR %.2f dB, SSIM %.5f", d.MaxError, d.MeanError, d.PSNR, d.SSIM)
+}
+
+// CompareCanvases measures the differences between two canvases of the
+// same size.
+func CompareCanvases(a, b Canvas) (ImageDiff, error) {
+	if a.Width != b.Width || a.Height != b.Height {
+		return ImageDiff{}, fmt.Errorf("canvases are %dx%d and %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	d := ImageDiff{PSNR: math.Inf(1), SSIM: 1}
+	if len(a.Pixels) == 0 {
+		return d, nil
+	}
+	sum, sumSquares := 0.0, 0.0
+	for i, p := range a.Pixels {
+		q := b.Pixels[i]
+		for _, e := range []float64{p.Red - q.Red, p.Green - q.Green, p.Blue - q.Blue} {
+			e = math.Abs(e)
+			d.MaxError = math.Max(d.MaxError, e)
+			sum += e
+			sumSquares += e * e
+		}
+	}
+	n := float64(len(a.Pixels) * 3)
+	d.MeanError = sum / n
+	if mse := sumSquares / n; mse > 0 {
+		d.PSNR = 10 * math.Log10(1/mse)
+	}
+	d.SSIM = ssim(a, b)
+	return d, nil
+}
+
+// ssim is the mean structural similarity of the two canvases' luminance,
+// using an 11 pixel Gaussian window with a sigma of 1.5 (Wang et al.).
+// Windows are cut off at the edges of the canvas.
+func ssim(a, b Canvas) float64 {
+	const radius, sigma = 5, 1.5
+	const c1, c2 = 0.01 * 0.01, 0.03 * 0.03
+	weights := [2*radius + 1]float64{}
+	for i := range weights {
+		x := float64(i - radius)
+		weights[i] = math.Exp(-x * x / (2 * sigma * sigma))
+	}
+	la := make([]float64, len(a.Pixels))
+	lb := make([]float64, len(b.Pixels))
+	for i := range a.Pixels {
+		la[i] = luminance(a.Pixels[i])
+		lb[i] = luminance(b.Pixels[i])
+	}
+
+	total := 0.0
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			var w, ma, mb, saa, sbb, sab float64
+			for j := -radius; j <= radius; j++ {
+				if y+j < 0 || y+j >= a.Height {
+					continue
+				}
+				for i := -radius; i <= radius; i++ {
+					if x+i < 0 || x+i >= a.Width {
+						continue
+					}
+					k := weights[i+radius] * weights[j+radius]
+					p := (y+j)*a.Width + x + i
+					w += k
+					ma += k * la[p]
+					mb += k * lb[p]
+					saa += k * la[p] * la[p]
+					sbb += k * lb[p] * lb[p]
+					sab += k * la[p] * lb[p]
+				}
+			}
+			ma, mb = ma/w, mb/w
+			va, vb, cov := saa/w-ma*ma, sbb/w-mb*mb, sab/w-ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+		}
+	}
+	return total / float64(len(a.Pixels))
+}
+
+// DiffImage shows where two canvases differ: a dim grey copy of a, with
+// every pixel where some channel differs by more than threshold painted
+// red, brighter the bigger the difference.
+func DiffImage(a, b Canvas, threshold float64) (Canvas, error) {
+	if a.Width != b.Width || a.Height != b.Height {
+		return Canvas{}, fmt.Errorf("canvases are %dx%d and %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	out := NewCanvas(a.Width, a.Height)
+	for i, p := range a.Pixels {
+		q := b.Pixels[i]
+		e := math.Max(math.Abs(p.Red-q.Red), math.Max(math.Abs(p.Green-q.Green), math.Abs(p.Blue-q.Blue)))
+		if e > threshold {
+			out.Pixels[i] = NewColor(0.5+0.5*math.Min(e, 1), 0, 0)
+			continue
+		}
+		g := 0.3 * math.Min(math.Max(luminance(p), 0), 1)
+		out.Pixels[i] = NewColor(g, g, g)
+	}
+	return out, nil
+}
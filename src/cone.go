@@ -2,12 +2,11 @@ package main
 
 import (
 	"math"
-	"math/rand"
-	"time"
 )
 
 type Cone struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
@@ -20,9 +19,8 @@ type Cone struct {
 }
 
 func NewCone() *Cone {
-	rand.Seed(time.Now().UnixNano())
 	return &Cone{
-		ID:        rand.Intn(100000),
+		ID:        nextShapeID(),
 		Transform: BaseTransform,
 		Origin:    BaseOrigin,
 		Material:  BaseMaterial,
@@ -190,3 +188,7 @@ func (s *Cone) Bounds() *Bounds {
 
 	return b
 }
+
+func (s *Cone) GetParent() *Group {
+	return s.Parent
+}
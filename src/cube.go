@@ -2,12 +2,11 @@ package main
 
 import (
 	"math"
-	"math/rand"
-	"time"
 )
 
 type Cube struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
@@ -17,9 +16,8 @@ type Cube struct {
 }
 
 func NewCube() *Cube {
-	rand.Seed(time.Now().UnixNano())
 	return &Cube{
-		ID:        rand.Intn(100000),
+		ID:        nextShapeID(),
 		Transform: BaseTransform,
 		Origin:    BaseOrigin,
 		Material:  BaseMaterial,
@@ -141,3 +139,7 @@ func (s *Cube) Bounds() *Bounds {
 	b.Maximum = NewPoint(1, 1, 1)
 	return b
 }
+
+func (s *Cube) GetParent() *Group {
+	return s.Parent
+}
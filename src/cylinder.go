@@ -2,12 +2,11 @@ package main
 
 import (
 	"math"
-	"math/rand"
-	"time"
 )
 
 type Cylinder struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
@@ -20,9 +19,8 @@ type Cylinder struct {
 }
 
 func NewCylinder() *Cylinder {
-	rand.Seed(time.Now().UnixNano())
 	return &Cylinder{
-		ID:        rand.Intn(100000),
+		ID:        nextShapeID(),
 		Transform: BaseTransform,
 		Origin:    BaseOrigin,
 		Radius:    1,
@@ -188,3 +186,7 @@ func (s *Cylinder) Bounds() *Bounds {
 func (s *Cylinder) SetParent(g *Group) {
 	s.Parent = g
 }
+
+func (s *Cylinder) GetParent() *Group {
+	return s.Parent
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// DenoiseGuides are the AOVs a Denoiser reads when they are in the buffers
+// it is given.
+var DenoiseGuides = []string{"normal", "albedo", "depth"}
+
+// Denoiser is a joint bilateral filter. Each pixel becomes a weighted mean
+// of its neighbours within Radius, each weighted by a Gaussian of how far
+// away it is and of how different its colour, normal, albedo and depth
+// are. Neighbours across an edge in any of the guides count for almost
+// nothing, so edges stay sharp while noise on flat surfaces is smoothed.
+// A sigma of 0 leaves that term out.
+type Denoiser struct {
+	Radius       int
+	SpatialSigma float64
+	ColorSigma   float64
+	NormalSigma  float64
+	AlbedoSigma  float64
+	// DepthSigma is relative: a difference in depth is divided by the
+	// larger depth
+	DepthSigma float64
+}
+
+func NewDenoiser() Denoiser {
+	return Denoiser{
+		Radius:       3,
+		SpatialSigma: 2,
+		ColorSigma:   0.5,
+		NormalSigma:  0.2,
+		AlbedoSigma:  0.1,
+		DepthSigma:   0.05,
+	}
+}
+
+// Denoise filters the canvas guided by whichever of DenoiseGuides are in
+// buffers, which must match the canvas in size. Alpha is filtered with the
+// same weights as the premultiplied colours.
+func (d Denoiser) Denoise(c Canvas, buffers AOVBuffers) (Canvas, error) {
+	guides := map[string]*Canvas{}
+	for _, name := range DenoiseGuides {
+		b, ok := buffers[name]
+		if !ok {
+			continue
+		}
+		if b.Width != c.Width || b.Height != c.Height {
+			return Canvas{}, fmt.Errorf("the %q buffer is %dx%d, not %dx%d", name, b.Width, b.Height, c.Width, c.Height)
+		}
+		guides[name] = &b
+	}
+
+	out := NewCanvas(c.Width, c.Height)
+	if c.HasAlpha() {
+		out.AddAlpha()
+	}
+	rows := make(chan int, c.Height)
+	for y := 0; y < c.Height; y++ {
+		rows <- y
+	}
+	close(rows)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				for x := 0; x < c.Width; x++ {
+					col, alpha := d.denoisePixel(&c, guides, x, y)
+					out.Pixels[y*c.Width+x] = col
+					out.WriteAlpha(x, y, alpha)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return out, nil
+}
+
+func (d Denoiser) denoisePixel(c *Canvas, guides map[string]*Canvas, x, y int) (Color, float64) {
+	p := y*c.Width + x
+	sum := NewColor(0, 0, 0)
+	alpha := 0.0
+	total := 0.0
+	for j := -d.Radius; j <= d.Radius; j++ {
+		for i := -d.Radius; i <= d.Radius; i++ {
+			if !c.inside(x+i, y+j) {
+				continue
+			}
+			q := (y+j)*c.Width + x + i
+			w := gaussianTerm(float64(i*i+j*j), d.SpatialSigma) *
+				gaussianTerm(colorDistance(c.Pixels[p], c.Pixels[q]), d.ColorSigma)
+			if g, ok := guides["normal"]; ok {
+				w *= gaussianTerm(colorDistance(g.Pixels[p], g.Pixels[q]), d.NormalSigma)
+			}
+			if g, ok := guides["albedo"]; ok {
+				w *= gaussianTerm(colorDistance(g.Pixels[p], g.Pixels[q]), d.AlbedoSigma)
+			}
+			if g, ok := guides["depth"]; ok {
+				w *= d.depthWeight(g.Pixels[p].Red, g.Pixels[q].Red)
+			}
+			sum = sum.Add(c.Pixels[q].MultiplyScalar(w))
+			alpha += c.AlphaAt(x+i, y+j) * w
+			total += w
+		}
+	}
+	// The pixel itself always has a weight of 1, so total is never 0
+	return sum.MultiplyScalar(1 / total), alpha / total
+}
+
+// depthWeight keeps hits and misses, which have a depth of 0, apart.
+func (d Denoiser) depthWeight(a, b float64) float64 {
+	if (a > 0) != (b > 0) {
+		return 0
+	}
+	far := math.Max(a, b)
+	if far == 0 {
+		return 1
+	}
+	diff := (a - b) / far
+	return gaussianTerm(diff*diff, d.DepthSigma)
+}
+
+// gaussianTerm weights a squared distance; a sigma of 0 weights everything
+// alike.
+func gaussianTerm(distanceSquared, sigma float64) float64 {
+	if sigma <= 0 {
+		return 1
+	}
+	return math.Exp(-distanceSquared / (2 * sigma * sigma))
+}
+
+func colorDistance(a, b Color) float64 {
+	dr, dg, db := a.Red-b.Red, a.Green-b.Green, a.Blue-b.Blue
+	return dr*dr + dg*dg + db*db
+}
+
+// RenderDenoised renders the world with the buffers the denoiser reads and
+// returns the denoised image.
+func (c *Camera) RenderDenoised(w World, d Denoiser) (Canvas, error) {
+	image, buffers, err := c.RenderWithAOVs(w, DenoiseGuides...)
+	if err != nil {
+		return image, err
+	}
+	return d.Denoise(image, buffers)
+}
@@ -0,0 +1,160 @@
+package main
+
+import "math"
+
+// DisplayTransform turns the linear light a camera records into values for
+// a screen: an exposure, then a tone curve squeezing highlights into [0, 1],
+// then the sRGB transfer curve. NewDisplayTransform only encodes sRGB, which
+// is what screens and image viewers expect of PPM, PNG and JPEG files; the
+// zero transform leaves colours as they are.
+type DisplayTransform struct {
+	// Exposure brightens or darkens by this many stops
+	Exposure float64
+	// AutoExposure scales the log average luminance to middle grey before
+	// Exposure is applied
+	AutoExposure bool
+	// ToneMap is one of ToneMaps; "" is the same as "none"
+	ToneMap string
+	SRGB    bool
+}
+
+var ToneMaps = []string{"none", "reinhard", "filmic", "aces"}
+
+// middleGrey is the luminance auto exposure maps the log average to.
+const middleGrey = 0.18
+
+func NewDisplayTransform() DisplayTransform {
+	return DisplayTransform{ToneMap: "none", SRGB: true}
+}
+
+func (d DisplayTransform) IsIdentity() bool {
+	return d.Exposure == 0 && !d.AutoExposure && (d.ToneMap == "" || d.ToneMap == "none") && !d.SRGB
+}
+
+func luminance(c Color) float64 {
+	return 0.2126*c.Red + 0.7152*c.Green + 0.0722*c.Blue
+}
+
+// LogAverageLuminance is the geometric mean of the canvas's luminance, which
+// a few bright pixels cannot drag up the way they would an arithmetic mean.
+func LogAverageLuminance(c *Canvas) float64 {
+	if len(c.Pixels) == 0 {
+		return 0
+	}
+	const delta = 0.0001
+	sum := 0.0
+	for _, col := range c.Pixels {
+		sum += math.Log(delta + math.Max(luminance(col), 0))
+	}
+	return math.Exp(sum / float64(len(c.Pixels)))
+}
+
+// Scale is what the canvas is multiplied by before tone mapping.
+func (d DisplayTransform) Scale(c *Canvas) float64 {
+	scale := math.Exp2(d.Exposure)
+	if d.AutoExposure {
+		if avg := LogAverageLuminance(c); avg > 0 {
+			scale *= middleGrey / avg
+		}
+	}
+	return scale
+}
+
+// Apply returns a display referred copy of the canvas.
+func (d DisplayTransform) Apply(c *Canvas) Canvas {
+	out := NewCanvas(c.Width, c.Height)
+	scale := d.Scale(c)
+	curve := toneCurve(d.ToneMap)
+	for i, col := range c.Pixels {
+		v := [3]float64{col.Red * scale, col.Green * scale, col.Blue * scale}
+		for j := range v {
+			v[j] = curve(v[j])
+			if d.SRGB {
+				v[j] = encodeSRGB(v[j])
+			}
+		}
+		out.Pixels[i] = NewColor(v[0], v[1], v[2])
+	}
+	return out
+}
+
+// ScaleOnly returns a copy of the canvas with just the exposure applied, for
+// formats that keep linear light.
+func (d DisplayTransform) ScaleOnly(c *Canvas) Canvas {
+	out := NewCanvas(c.Width, c.Height)
+	scale := d.Scale(c)
+	for i, col := range c.Pixels {
+		out.Pixels[i] = col.MultiplyScalar(scale)
+	}
+	return out
+}
+
+func toneCurve(name string) func(float64) float64 {
+	switch name {
+	case "reinhard":
+		return reinhard
+	case "filmic":
+		return filmic
+	case "aces":
+		return aces
+	}
+	return func(v float64) float64 { return v }
+}
+
+func reinhard(v float64) float64 {
+	v = math.Max(v, 0)
+	return v / (1 + v)
+}
+
+// hable is John Hable's curve from Uncharted 2.
+func hable(v float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (v*(a*v+c*b)+d*e)/(v*(a*v+b)+d*f) - e/f
+}
+
+// filmic runs the Hable curve with its usual exposure bias of 2 and white
+// point of 11.2.
+func filmic(v float64) float64 {
+	const bias, white = 2.0, 11.2
+	return hable(math.Max(v, 0)*bias) / hable(white)
+}
+
+// aces is Krzysztof Narkowicz's fit to the ACES reference rendering
+// transform.
+func aces(v float64) float64 {
+	v = math.Max(v, 0)
+	return math.Min(v*(2.51*v+0.03)/(v*(2.43*v+0.59)+0.14), 1)
+}
+
+func encodeSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func decodeSRGB(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// decodeSRGB turns a canvas read from a display referred file back into
+// linear light, in place. Premultiplied colours are divided by their alpha
+// around the curve.
+func (c *Canvas) decodeSRGB() {
+	for i, col := range c.Pixels {
+		a := 1.0
+		if c.HasAlpha() {
+			a = c.Alpha[i]
+		}
+		if a <= 0 {
+			continue
+		}
+		c.Pixels[i] = NewColor(
+			decodeSRGB(col.Red/a)*a,
+			decodeSRGB(col.Green/a)*a,
+			decodeSRGB(col.Blue/a)*a)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The tile protocol is a stream of gob values over one connection per
+// worker. The coordinator sends the SceneDescription, then a tileJob at a
+// time; the worker answers each job with a tileResult. A tileJob with Done
+// set tells the worker the render is finished.
+type tileJob struct {
+	Tile Tile
+	Done bool
+}
+
+type tileResult struct {
+	Tile   Tile
+	Colors []Color
+	// Alpha is only sent for cameras with Alpha set
+	Alpha []float64
+}
+
+// Coordinator hands the tiles of one render out to workers that connect to
+// it, and puts the pixels they send back together. A tile whose worker
+// disconnects before answering, or takes longer than TileTimeout over it,
+// goes back on the queue for someone else.
+type Coordinator struct {
+	Scene       SceneDescription
+	TileTimeout time.Duration
+	camera      Camera
+}
+
+func NewCoordinator(w World, c Camera) (*Coordinator, error) {
+	d, err := DescribeScene(w, c)
+	if err != nil {
+		return nil, err
+	}
+	return &Coordinator{Scene: d, TileTimeout: 10 * time.Minute, camera: c}, nil
+}
+
+// Serve accepts workers on l until every tile in the camera's crop window
+// has been traced, then closes l and returns the canvas, laid out as Render
+// would. If ctx is cancelled first, the partial canvas is returned with
+// ctx's error.
+func (co *Coordinator) Serve(ctx context.Context, l net.Listener) (Canvas, error) {
+	crop := co.camera.CropWindow()
+	image := co.camera.newImage()
+	tiles := co.camera.Tiles()
+	queue := make(chan Tile, len(tiles))
+	for _, t := range tiles {
+		queue <- t
+	}
+	remaining := int64(len(tiles))
+	finished := make(chan struct{})
+	if remaining == 0 {
+		close(finished)
+	}
+	put := func(r tileResult) {
+		for i, col := range r.Colors {
+			x, y := int(r.Tile.X-crop.X)+i%int(r.Tile.Width), int(r.Tile.Y-crop.Y)+i/int(r.Tile.Width)
+			image.WritePixel(x, y, col)
+			if image.HasAlpha() {
+				image.WriteAlpha(x, y, r.Alpha[i])
+			}
+		}
+		if atomic.AddInt64(&remaining, -1) == 0 {
+			close(finished)
+		}
+	}
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var workers sync.WaitGroup
+	accepting := make(chan struct{})
+	go func() {
+		defer close(accepting)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				co.serveWorker(workerCtx, conn, queue, finished, put)
+			}()
+		}
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+	l.Close()
+	<-accepting
+	workers.Wait()
+	return image, ctx.Err()
+}
+
+func (co *Coordinator) serveWorker(ctx context.Context, conn net.Conn, queue chan Tile, finished chan struct{}, put func(r tileResult)) {
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+	// Every exchange has to finish by its deadline, so a worker that hangs
+	// with its connection open cannot keep a tile forever
+	conn.SetDeadline(time.Now().Add(co.TileTimeout))
+	if err := enc.Encode(co.Scene); err != nil {
+		return
+	}
+	for {
+		select {
+		case t := <-queue:
+			r := tileResult{}
+			conn.SetDeadline(time.Now().Add(co.TileTimeout))
+			err := enc.Encode(tileJob{Tile: t})
+			if err == nil {
+				err = dec.Decode(&r)
+			}
+			if err == nil && (r.Tile != t || int64(len(r.Colors)) != t.Width*t.Height) {
+				err = fmt.Errorf("worker answered tile %v with %v", t, r.Tile)
+			}
+			if err == nil && co.camera.Alpha && int64(len(r.Alpha)) != t.Width*t.Height {
+				err = fmt.Errorf("worker sent %d alpha values for tile %v", len(r.Alpha), t)
+			}
+			if err != nil {
+				queue <- t
+				return
+			}
+			put(r)
+		case <-finished:
+			conn.SetDeadline(time.Now().Add(co.TileTimeout))
+			enc.Encode(tileJob{Done: true})
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// workerWriteTimeout bounds how long a worker waits to hand a finished tile
+// back. Reads have no deadline: a coordinator may rightly leave a worker
+// idle while others finish the last tiles.
+const workerWriteTimeout = time.Minute
+
+// RunWorker traces tiles for the coordinator at the other end of conn until
+// it says the render is finished, the connection drops or ctx is cancelled.
+func RunWorker(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+	scene := SceneDescription{}
+	if err := dec.Decode(&scene); err != nil {
+		return err
+	}
+	w, c, err := scene.Build()
+	if err != nil {
+		return err
+	}
+	for {
+		job := tileJob{}
+		if err := dec.Decode(&job); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if job.Done {
+			return nil
+		}
+		t := job.Tile
+		r := tileResult{Tile: t, Colors: make([]Color, t.Width*t.Height)}
+		if c.Alpha {
+			r.Alpha = make([]float64, t.Width*t.Height)
+		}
+		if !c.traceTile(ctx, w, t, nil, func(x, y int64, col Color, alpha float64) {
+			i := (y-t.Y)*t.Width + (x - t.X)
+			r.Colors[i] = col
+			if r.Alpha != nil {
+				r.Alpha[i] = alpha
+			}
+		}) {
+			return ctx.Err()
+		}
+		conn.SetWriteDeadline(time.Now().Add(workerWriteTimeout))
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+}
+
+// DialWorker connects to the coordinator at addr and works for it.
+func DialWorker(ctx context.Context, addr string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return RunWorker(ctx, conn)
+}
@@ -1,16 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
 	"io/ioutil"
 	"log"
 	"math"
+	"net"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cucumber/godog"
 	"github.com/cucumber/messages-go/v16"
@@ -37,6 +51,20 @@ type floats map[string]float64
 type bounds map[string]Bounds
 type files map[string]string
 type parsers map[string]Parser
+type filters map[string]Filter
+type distortions map[string]*LensDistortion
+type progresses map[string][]Progress
+type errs map[string]error
+type coordinators map[string]*servedRender
+type stereos map[string]StereoRig
+type displays map[string]DisplayTransform
+type aovsamples map[string]AOVSample
+type aovbuffers map[string]AOVBuffers
+type imagediffs map[string]ImageDiff
+type posts map[string]PostProcess
+type animations map[string]*Animation
+type gifs map[string]*gif.GIF
+type denoisers map[string]Denoiser
 
 type tupletest struct {
 	Tuples             tuples
@@ -59,6 +87,20 @@ type tupletest struct {
 	Bounds             bounds
 	Files              files
 	Parsers            parsers
+	Filters            filters
+	Distortions        distortions
+	Progresses         progresses
+	Errors             errs
+	Coordinators       coordinators
+	Stereos            stereos
+	Displays           displays
+	AOVSamples         aovsamples
+	AOVBuffers         aovbuffers
+	ImageDiffs         imagediffs
+	PostProcesses      posts
+	Animations         animations
+	GIFs               gifs
+	Denoisers          denoisers
 }
 
 var opts = godog.Options{
@@ -98,6 +140,20 @@ func TestFeatures(t *testing.T) {
 				tt.Bounds = bounds{}
 				tt.Files = files{}
 				tt.Parsers = parsers{}
+				tt.Filters = filters{}
+				tt.Distortions = distortions{}
+				tt.Progresses = progresses{}
+				tt.Errors = errs{}
+				tt.Coordinators = coordinators{}
+				tt.Stereos = stereos{}
+				tt.Displays = displays{}
+				tt.AOVSamples = aovsamples{}
+				tt.AOVBuffers = aovbuffers{}
+				tt.ImageDiffs = imagediffs{}
+				tt.PostProcesses = posts{}
+				tt.Animations = animations{}
+				tt.GIFs = gifs{}
+				tt.Denoisers = denoisers{}
 				return ctx, nil
 			})
 
@@ -285,6 +341,7 @@ func TestFeatures(t *testing.T) {
 			ctx.Step(`^sphere\.([a-zA-Z0-9_]+) ← the second object in world\.([a-zA-Z0-9_]+)$`, tt.sphereshapeTheSecondObjectInWorldw)
 			ctx.Step(`^world\.([a-zA-Z0-9_]+)\.light ← point_light\(point\((.+?), (.+?), (.+?)\), color\((.+?), (.+?), (.+?)\)\)$`, tt.worldwlightPoint_lightpointColor)
 			ctx.Step(`^colors\.([a-zA-Z0-9_]+) ← color_at\(world\.([a-zA-Z0-9_]+), ray\.([a-zA-Z0-9_]+)\)$`, tt.colorscColor_atworldwRayr)
+			ctx.Step(`^colors\.([a-zA-Z0-9_]+) ← color_at\(world\.([a-zA-Z0-9_]+), ray\.([a-zA-Z0-9_]+)\) from (\d+) goroutines$`, tt.colorscColor_atworldwRayrFromGoroutines)
 
 			ctx.Step(`^matrix\.([a-zA-Z0-9_]+) = scaling\(([^,]+), ([^,]+), ([^)]+)\)$`, tt.matrixtScalingEqual)
 			ctx.Step(`^matrix\.([a-zA-Z0-9_]+) = translation\(([^,]+), ([^,]+), ([^)]+)\)$`, tt.matrixtTranslationEqual)
@@ -303,6 +360,10 @@ func TestFeatures(t *testing.T) {
 			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.transform ← view_transform\(tuple\.([a-zA-Z0-9_]+), tuple\.([a-zA-Z0-9_]+), tuple\.([a-zA-Z0-9_]+)\)$`, tt.cameractransformView_transformtuplefromTupletoTupleup)
 			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\)$`, tt.canvasimageRendercameracWorldw)
 			ctx.Step(`^pixel_at\(canvas\.([a-zA-Z0-9_]+), (\d+), (\d+)\) = color\((.*), (.*), (.*)\)$`, tt.pixel_atcanvasimageColor)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.workers ← (\d+)$`, tt.cameracworkers)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.tile_size ← (\d+)$`, tt.cameractile_size)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) has (\d+) tiles$`, tt.cameracHasTiles)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) = canvas\.([a-zA-Z0-9_]+)$`, tt.canvasaEqualsCanvasb)
 
 			// CHAPTER 8
 
@@ -481,6 +542,192 @@ func TestFeatures(t *testing.T) {
 			ctx.Step(`^shapes\.([a-zA-Z0-9_]+) includes "([^"]*)" from parsers\.([a-zA-Z0-9_]+)$`, tt.shapesgIncludesFromParsersparser)
 			ctx.Step(`^shapes\.([a-zA-Z0-9_]+) ← obj_to_group\(parsers\.([a-zA-Z0-9_]+)\)$`, tt.shapesgObj_to_groupparsersparser)
 
+			// Sampling
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.samples ← (\d+)$`, tt.cameracsamples)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.sample_mode ← "([^"]*)"$`, tt.cameracsample_mode)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.seed ← (\d+)$`, tt.cameracseed)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.filter ← filters\.([a-zA-Z0-9_]+)$`, tt.cameracfilterFilters)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) has (\d+) samples for pixel (\d+), (\d+)$`, tt.cameracHasSamplesForPixel)
+			ctx.Step(`^sample (\d+) of camera\.([a-zA-Z0-9_]+) for pixel (\d+), (\d+) = \((.+), (.+)\)$`, tt.sampleOfCameracForPixel)
+			ctx.Step(`^every sample of camera\.([a-zA-Z0-9_]+) for pixel (\d+), (\d+) lies in its stratum$`, tt.everySampleOfCameracLiesInItsStratum)
+			ctx.Step(`^filters\.([a-zA-Z0-9_]+) ← box_filter\(\)$`, tt.filtersfBox_filter)
+			ctx.Step(`^filters\.([a-zA-Z0-9_]+) ← tent_filter\((.+)\)$`, tt.filtersfTent_filter)
+			ctx.Step(`^filters\.([a-zA-Z0-9_]+) ← gaussian_filter\((.+), (.+)\)$`, tt.filtersfGaussian_filter)
+			ctx.Step(`^filters\.([a-zA-Z0-9_]+) ← mitchell_filter\((.+), (.+), (.+)\)$`, tt.filtersfMitchell_filter)
+			ctx.Step(`^weight\(filters\.([a-zA-Z0-9_]+), (.+), (.+)\) = (.+)$`, tt.weightFiltersfEquals)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.adaptive_threshold ← (.+)$`, tt.cameracadaptive_threshold)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.adaptive_depth ← (\d+)$`, tt.cameracadaptive_depth)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) fired (\d+) camera rays and (\d+) extra rays$`, tt.cameracFiredCameraRaysAndExtraRays)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) fired some extra rays$`, tt.cameracFiredSomeExtraRays)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.aperture ← (.+)$`, tt.cameracaperture)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.focal_distance ← (.+)$`, tt.cameracfocal_distance)
+			ctx.Step(`^ray\.([a-zA-Z0-9_]+) ← ray_for_sample\(camera\.([a-zA-Z0-9_]+), (\d+), (\d+), (.+), (.+), lens (.+), (.+)\)$`, tt.rayrRay_for_samplecamerac)
+			ctx.Step(`^every lens sample of camera\.([a-zA-Z0-9_]+) for pixel (\d+), (\d+) lies on the unit disk$`, tt.everyLensSampleOfCameracLiesOnTheUnitDisk)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) ← orthographic_camera\(([^,]+), ([^,]+), ([^,]+), ([^)]+)\)$`, tt.cameracOrthographic_camera)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) ← panoramic_camera\(([^,]+), ([^)]+)\)$`, tt.cameracPanoramic_camera)
+			ctx.Step(`^the cube map of camera\.([a-zA-Z0-9_]+) in world\.([a-zA-Z0-9_]+) is rendered (\d+) pixels across$`, tt.theCubeMapOfCameracInWorldwIsRendered)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← cube_map_cross\(\)$`, tt.canvascCube_map_cross)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) ← fisheye_camera\(([^,]+), ([^,]+), ([^,]+), "([^"]*)"\)$`, tt.cameracFisheye_camera)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) has no ray for pixel (\d+), (\d+)$`, tt.cameracHasNoRayForPixel)
+			ctx.Step(`^distortions\.([a-zA-Z0-9_]+) ← brown_conrady\(([^,]+), ([^,]+), ([^,]+), ([^,]+), ([^)]+)\)$`, tt.distortionsdBrown_conrady)
+			ctx.Step(`^distort\(distortions\.([a-zA-Z0-9_]+), ([^,]+), ([^)]+)\) = \(([^,]+), ([^)]+)\)$`, tt.distortDistortionsd)
+			ctx.Step(`^undistort\(distortions\.([a-zA-Z0-9_]+), ([^,]+), ([^)]+)\) = \(([^,]+), ([^)]+)\)$`, tt.undistortDistortionsd)
+			ctx.Step(`^undistort\(distortions\.([a-zA-Z0-9_]+)\) reverses distort\(distortions\.([a-zA-Z0-9_]+), ([^,]+), ([^)]+)\)$`, tt.undistortReversesDistort)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.distortion ← distortions\.([a-zA-Z0-9_]+)$`, tt.cameracdistortionDistortionsd)
+			ctx.Step(`^ray\.([a-zA-Z0-9_]+) ← ray\(point\((.+), (.+), (.+)\), vector\((.+), (.+), (.+)\)\) at time (.+)$`, tt.rayrRaypointVectorAtTime)
+			ctx.Step(`^ray\.([a-zA-Z0-9_]+)\.time = (.+)$`, tt.rayrtime)
+			ctx.Step(`^set_end_transform\(shapes\.([a-zA-Z0-9_]+), translation\((.+), (.+), (.+)\)\)$`, tt.set_end_transformshapessTranslation)
+			ctx.Step(`^set_end_transform\(shapes\.([a-zA-Z0-9_]+), rotation_y\((.+)\)\)$`, tt.set_end_transformshapessRotation_y)
+			ctx.Step(`^transform_at\(shapes\.([a-zA-Z0-9_]+), (.+)\) = translation\((.+), (.+), (.+)\)$`, tt.transform_atshapessTranslation)
+			ctx.Step(`^transform_at\(shapes\.([a-zA-Z0-9_]+), (.+)\) = rotation_y\((.+)\)$`, tt.transform_atshapessRotation_y)
+			ctx.Step(`^bounds\.([a-zA-Z0-9_]+) ← bounds\(shapes\.([a-zA-Z0-9_]+)\)$`, tt.boundsbBoundsshapess)
+			ctx.Step(`^bounds\.([a-zA-Z0-9_]+)\.minpoint = point\((-?[\d.]+), (-?[\d.]+), (-?[\d.]+)\)$`, tt.boundsbminpointPoint)
+			ctx.Step(`^bounds\.([a-zA-Z0-9_]+)\.maxpoint = point\((-?[\d.]+), (-?[\d.]+), (-?[\d.]+)\)$`, tt.boundsbmaxpointPoint)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.shutter ← (.+), (.+)$`, tt.cameracshutter)
+			ctx.Step(`^ray\.([a-zA-Z0-9_]+) ← ray_for_sample\(camera\.([a-zA-Z0-9_]+), (\d+), (\d+), (.+), (.+), time (.+)\)$`, tt.rayrRay_for_samplecameracTime)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) sample times for pixel (\d+), (\d+) cover the shutter$`, tt.cameracSampleTimesCoverTheShutter)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.crop ← \((-?\d+), (-?\d+), (\d+), (\d+)\)$`, tt.cameraccrop)
+			ctx.Step(`^render_into\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.render_intocameracWorldwCanvasc)
+			ctx.Step(`^files\.([a-zA-Z0-9_]+) ← a temporary checkpoint$`, tt.filescATemporaryCheckpoint)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render_with_checkpoint\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\)$`, tt.canvasimageRender_with_checkpoint)
+			ctx.Step(`^files\.([a-zA-Z0-9_]+) loses its last (\d+) bytes$`, tt.filescLosesItsLastBytes)
+			ctx.Step(`^load_checkpoint\(files\.([a-zA-Z0-9_]+), camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) is rejected$`, tt.load_checkpointIsRejected)
+			ctx.Step(`^load_checkpoint\(files\.([a-zA-Z0-9_]+), camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) has (\d+) tiles$`, tt.load_checkpointHasTiles)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render_with_progress\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\)$`, tt.canvasimageRender_with_progress)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render_with_progress\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) cancelled after (\d+) tiles$`, tt.canvasimageRender_with_progressCancelledAfter)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) reported progress (\d+) times$`, tt.cameracReportedProgressTimes)
+			ctx.Step(`^the last progress of camera\.([a-zA-Z0-9_]+) is (\d+) of (\d+) tiles and (\d+) of (\d+) pixels$`, tt.theLastProgressOfCamerac)
+			ctx.Step(`^the progress of camera\.([a-zA-Z0-9_]+) never goes backwards$`, tt.theProgressOfCameracNeverGoesBackwards)
+			ctx.Step(`^the render of canvas\.([a-zA-Z0-9_]+) was cancelled$`, tt.theRenderOfCanvascWasCancelled)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) after a scene round trip$`, tt.canvasimageRenderAfterASceneRoundTrip)
+			ctx.Step(`^describing camera\.([a-zA-Z0-9_]+) and world\.([a-zA-Z0-9_]+) fails$`, tt.describingCameracAndWorldwFails)
+			ctx.Step(`^coordinators\.([a-zA-Z0-9_]+) ← coordinator\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) on loopback$`, tt.coordinatorsCoordinatorOnLoopback)
+			ctx.Step(`^(\d+) workers? joins? coordinators\.([a-zA-Z0-9_]+)$`, tt.workersJoinCoordinators)
+			ctx.Step(`^a worker takes a tile from coordinators\.([a-zA-Z0-9_]+) and dies$`, tt.aWorkerTakesATileFromCoordinatorsAndDies)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← the render from coordinators\.([a-zA-Z0-9_]+)$`, tt.canvasimageTheRenderFromCoordinators)
+			ctx.Step(`^stereo\.([a-zA-Z0-9_]+) ← stereo_rig\(camera\.([a-zA-Z0-9_]+), (.+), (.+)\)$`, tt.stereorStereo_rig)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+) ← the (left|right) eye of stereo\.([a-zA-Z0-9_]+)$`, tt.cameraeTheEyeOfStereor)
+			ctx.Step(`^the centre rays of stereo\.([a-zA-Z0-9_]+) cross at point\((.+), (.+), (.+)\)$`, tt.theCentreRaysOfStereorCrossAtPoint)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← compose_stereo\(canvas\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+), "([^"]+)"\)$`, tt.canvascCompose_stereo)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render_stereo\(stereo\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+), "([^"]+)"\)$`, tt.canvascRender_stereo)
+			ctx.Step(`^compose_stereo\(canvas\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+), "([^"]+)"\) fails$`, tt.compose_stereoFails)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← write_ppm\(canvas\.([a-zA-Z0-9_]+)\)$`, tt.ppmWrite_ppmcanvasc)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) = ppm\.([a-zA-Z0-9_]+)$`, tt.ppmaEqualsPpmb)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← region\(canvas\.([a-zA-Z0-9_]+), (-?\d+), (-?\d+), (\d+), (\d+)\)$`, tt.canvasrRegioncanvasc)
+			ctx.Step(`^set_region\(canvas\.([a-zA-Z0-9_]+), (-?\d+), (-?\d+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.set_regioncanvascCanvasr)
+			ctx.Step(`^row (\d+) of canvas\.([a-zA-Z0-9_]+) has (\d+) pixels$`, tt.rowOfCanvascHasPixels)
+			ctx.Step(`^pixel (\d+) of row (\d+) of canvas\.([a-zA-Z0-9_]+) = colors\.([a-zA-Z0-9_]+)$`, tt.pixelOfRowOfCanvascEqualsColors)
+			ctx.Step(`^files\.([a-zA-Z0-9_]+) ← a temporary file "([^"]+)"$`, tt.filesfATemporaryFile)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\)$`, tt.savecanvascFilesf)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) as a (\d+) bit PNG$`, tt.savecanvascFilesfPNGDepth)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← load\(files\.([a-zA-Z0-9_]+)\)$`, tt.canvascLoadfilesf)
+			ctx.Step(`^files\.([a-zA-Z0-9_]+) starts with the bytes ([0-9a-f]+)$`, tt.filesfStartsWithTheBytes)
+			ctx.Step(`^image_format\("([^"]*)"\) = "([^"]*)"$`, tt.image_formatEquals)
+			ctx.Step(`^image_format\("([^"]*)"\) fails$`, tt.image_formatFails)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) matches canvas\.([a-zA-Z0-9_]+) to within (\d+(?:\.\d+)?)$`, tt.canvasaMatchesCanvasbToWithin)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← canvas_from_image\(an? (\d+)x(\d+) image at \((-?\d+), (-?\d+)\) with colors\.([a-zA-Z0-9_]+) at \((-?\d+), (-?\d+)\)\)$`, tt.canvascCanvas_from_image)
+			ctx.Step(`^draw\(canvas\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.drawcanvasaCanvasb)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← a file containing:$`, tt.ppmpAFileContaining)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← a P6 file with header "([^"]*)" and the bytes ([0-9a-f]*)$`, tt.ppmpAP6FileWithHeaderAndTheBytes)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← canvas_from_ppm\(ppm\.([a-zA-Z0-9_]+)\)$`, tt.canvascCanvas_from_ppmppmp)
+			ctx.Step(`^canvas_from_ppm\(ppm\.([a-zA-Z0-9_]+)\) fails on line (\d+)$`, tt.canvas_from_ppmFailsOnLine)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← write_p6\(canvas\.([a-zA-Z0-9_]+)\)$`, tt.ppmpWrite_p6canvasc)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) has (\d+) bytes$`, tt.ppmpHasBytes)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) as a binary PPM$`, tt.savecanvascFilesfBinaryPPM)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← a file with header "([^"]*)" and the bytes ([0-9a-f]*)$`, tt.ppmpAP6FileWithHeaderAndTheBytes)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← canvas_from_(pfm|hdr)\(ppm\.([a-zA-Z0-9_]+)\)$`, tt.canvascCanvas_from_hdrppmp)
+			ctx.Step(`^canvas_from_(pfm|hdr)\(ppm\.([a-zA-Z0-9_]+)\) fails on line (\d+)$`, tt.canvas_from_hdrFailsOnLine)
+			ctx.Step(`^files\.([a-zA-Z0-9_]+) has (\d+) bytes$`, tt.filesfHasBytes)
+			ctx.Step(`^display\.([a-zA-Z0-9_]+) ← display_transform\(\)$`, tt.displaydDisplay_transform)
+			ctx.Step(`^display\.([a-zA-Z0-9_]+)\.exposure ← (-?\d+(?:\.\d+)?)$`, tt.displaydExposure)
+			ctx.Step(`^display\.([a-zA-Z0-9_]+)\.auto_exposure ← (true|false)$`, tt.displaydAuto_exposure)
+			ctx.Step(`^display\.([a-zA-Z0-9_]+)\.tone_map ← "([^"]*)"$`, tt.displaydTone_map)
+			ctx.Step(`^display\.([a-zA-Z0-9_]+)\.srgb ← (true|false)$`, tt.displaydSrgb)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← apply\(display\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.canvasoApplydisplaydCanvasc)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) with display\.([a-zA-Z0-9_]+)$`, tt.savecanvascFilesfWithDisplayd)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← encode\(canvas\.([a-zA-Z0-9_]+), "([^"]+)"\)$`, tt.ppmpEncodecanvasc)
+			ctx.Step(`^log_average_luminance\(canvas\.([a-zA-Z0-9_]+)\) = (-?\d+(?:\.\d+)?)$`, tt.log_average_luminanceEquals)
+			ctx.Step(`^aov\.([a-zA-Z0-9_]+) ← aov_at\(world\.([a-zA-Z0-9_]+), ray\.([a-zA-Z0-9_]+)\)$`, tt.aovaAov_atworldwRayr)
+			ctx.Step(`^aov\.([a-zA-Z0-9_]+)\.(hit|shadowed) is (true|false)$`, tt.aovaFlagIs)
+			ctx.Step(`^aov\.([a-zA-Z0-9_]+)\.depth = (-?\d+(?:\.\d+)?)$`, tt.aovaDepth)
+			ctx.Step(`^aov\.([a-zA-Z0-9_]+)\.normal = vector\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)$`, tt.aovaNormal)
+			ctx.Step(`^aov\.([a-zA-Z0-9_]+)\.albedo = color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)$`, tt.aovaAlbedo)
+			ctx.Step(`^aov\.([a-zA-Z0-9_]+)\.object_id is the id of sphere\.([a-zA-Z0-9_]+)$`, tt.aovaObject_idIsTheIdOfSpheres)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) with buffers\.([a-zA-Z0-9_]+) "([^"]*)"$`, tt.canvasimageRenderWithBuffers)
+			ctx.Step(`^render\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) with buffers\.([a-zA-Z0-9_]+) "([^"]*)" fails$`, tt.renderWithBuffersFails)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← the "([^"]+)" (buffer|image) of buffers\.([a-zA-Z0-9_]+)$`, tt.canvasdTheBufferOfBuffersb)
+			ctx.Step(`^buffers\.([a-zA-Z0-9_]+) has no "([^"]+)" buffer$`, tt.buffersbHasNoBuffer)
+			ctx.Step(`^save the "([^"]+)" buffer of buffers\.([a-zA-Z0-9_]+) to files\.([a-zA-Z0-9_]+)$`, tt.saveTheBufferOfBuffersbToFilesf)
+			ctx.Step(`^diff\.([a-zA-Z0-9_]+) ← compare\(canvas\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.diffdComparecanvasaCanvasb)
+			ctx.Step(`^compare\(canvas\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\) fails$`, tt.compareFails)
+			ctx.Step(`^diff\.([a-zA-Z0-9_]+)\.(max_error|mean_error|psnr|ssim) = (-?\d+(?:\.\d+)?)$`, tt.diffdMetricEquals)
+			ctx.Step(`^diff\.([a-zA-Z0-9_]+)\.(max_error|mean_error|psnr|ssim) (<|>) (-?\d+(?:\.\d+)?)$`, tt.diffdMetricCompares)
+			ctx.Step(`^diff\.([a-zA-Z0-9_]+)\.psnr is infinite$`, tt.diffdPsnrIsInfinite)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← diff_image\(canvas\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+), (\d+(?:\.\d+)?)\)$`, tt.canvasdDiff_image)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← a (\d+)x(\d+) checkerboard of color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\) and color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)$`, tt.canvascACheckerboard)
+			ctx.Step(`^check_golden\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\)$`, tt.check_goldencanvascFilesf)
+			ctx.Step(`^check_golden\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) fails with a diff image$`, tt.check_goldenFailsWithADiffImage)
+			ctx.Step(`^check_golden\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) fails for want of a reference$`, tt.check_goldenFailsWithoutReference)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render_chapter\("([^"]+)", (\d+), (\d+)\)$`, tt.canvascRender_chapter)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) matches the golden image "([^"]+)"$`, tt.canvascMatchesTheGoldenImage)
+			ctx.Step(`^post\.([a-zA-Z0-9_]+) ← post_process\("([^"]*)"\)$`, tt.postpPost_process)
+			ctx.Step(`^post_process\("([^"]*)"\) fails$`, tt.post_processFails)
+			ctx.Step(`^the effects of post\.([a-zA-Z0-9_]+) are "([^"]*)"$`, tt.theEffectsOfPostp)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← apply\(post\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.canvasoApplypostpCanvasc)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) with post\.([a-zA-Z0-9_]+)$`, tt.savecanvascFilesfWithPostp)
+			ctx.Step(`^the total of canvas\.([a-zA-Z0-9_]+) = color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)$`, tt.theTotalOfCanvasc)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+) ← animation\(\)$`, tt.animaAnimation)
+			ctx.Step(`^add_frame\(anim\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+)\)$`, tt.add_frameanimaCanvasc)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+)\.(delay|loop_count|palette_size) ← (-?\d+)$`, tt.animaSetting)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+)\.dither ← (true|false)$`, tt.animaDither)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+)\.palette ← "([0-9a-f,]+)"$`, tt.animaPalette)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+)\.encode\.display ← display\.([a-zA-Z0-9_]+)$`, tt.animaEncodeDisplay)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+) ← render_turntable\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+), (\d+)\)$`, tt.animaRender_turntable)
+			ctx.Step(`^anim\.([a-zA-Z0-9_]+) has (\d+) frames$`, tt.animaHasFrames)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← frame (\d+) of anim\.([a-zA-Z0-9_]+)$`, tt.canvasdFrameOfAnima)
+			ctx.Step(`^save_frames\(anim\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\)$`, tt.save_framesanimaFilesf)
+			ctx.Step(`^save_frames\(anim\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) fails$`, tt.save_framesFails)
+			ctx.Step(`^the directory of files\.([a-zA-Z0-9_]+) holds "([^"]*)"$`, tt.theDirectoryOfFilesfHolds)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← load\(frame (\d+) of files\.([a-zA-Z0-9_]+)\)$`, tt.canvasdLoadFrameOfFilesf)
+			ctx.Step(`^save_gif\(anim\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\)$`, tt.save_gifanimaFilesf)
+			ctx.Step(`^save_gif\(anim\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) fails$`, tt.save_gifFails)
+			ctx.Step(`^gif\.([a-zA-Z0-9_]+) ← load_gif\(files\.([a-zA-Z0-9_]+)\)$`, tt.gifgLoad_giffilesf)
+			ctx.Step(`^gif\.([a-zA-Z0-9_]+) has (\d+) frames$`, tt.gifgHasFrames)
+			ctx.Step(`^gif\.([a-zA-Z0-9_]+)\.(delay|loop_count) = (-?\d+)$`, tt.gifgSetting)
+			ctx.Step(`^gif\.([a-zA-Z0-9_]+) has a palette of (\d+) colors$`, tt.gifgHasAPaletteOf)
+			ctx.Step(`^frame (\d+) of gif\.([a-zA-Z0-9_]+) uses (\d+) colors$`, tt.frameOfGifgUsesColors)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← frame (\d+) of gif\.([a-zA-Z0-9_]+)$`, tt.canvasdFrameOfGifg)
+			ctx.Step(`^the mean of canvas\.([a-zA-Z0-9_]+) is within (\d+(?:\.\d+)?) of color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)$`, tt.theMeanOfCanvasc)
+			ctx.Step(`^denoiser\.([a-zA-Z0-9_]+) ← denoiser\(\)$`, tt.denoiserdDenoiser)
+			ctx.Step(`^denoiser\.([a-zA-Z0-9_]+)\.(radius|spatial_sigma|color_sigma|normal_sigma|albedo_sigma|depth_sigma) ← (\d+(?:\.\d+)?)$`, tt.denoiserdSetting)
+			ctx.Step(`^buffers\.([a-zA-Z0-9_]+) ← buffers\(\)$`, tt.buffersbBuffers)
+			ctx.Step(`^the "([^"]+)" buffer of buffers\.([a-zA-Z0-9_]+) ← canvas\.([a-zA-Z0-9_]+)$`, tt.theBufferOfBuffersbIs)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← a (\d+)x(\d+) canvas of color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\) left of x = (\d+) and color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\) from there$`, tt.canvascASplitCanvas)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← canvas\.([a-zA-Z0-9_]+) \+ canvas\.([a-zA-Z0-9_]+)$`, tt.canvascCanvasaPlusCanvasb)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← denoise\(denoiser\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+), buffers\.([a-zA-Z0-9_]+)\)$`, tt.canvasoDenoise)
+			ctx.Step(`^denoise\(denoiser\.([a-zA-Z0-9_]+), canvas\.([a-zA-Z0-9_]+), buffers\.([a-zA-Z0-9_]+)\) fails$`, tt.denoiseFails)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) ← render_denoised\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+), denoiser\.([a-zA-Z0-9_]+)\)$`, tt.canvasoRender_denoised)
+			ctx.Step(`^pixel_at\(canvas\.([a-zA-Z0-9_]+), (\d+), (\d+)\) is within (\d+(?:\.\d+)?) of color\((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)$`, tt.pixel_atIsWithin)
+			ctx.Step(`^pixel_at\(canvas\.([a-zA-Z0-9_]+), (\d+), (\d+)\)\.red (<|>) (-?\d+(?:\.\d+)?)$`, tt.pixel_atRedCompare)
+			ctx.Step(`^canvas\.([a-zA-Z0-9_]+) has (no )?alpha$`, tt.canvascHasAlpha)
+			ctx.Step(`^add_alpha\(canvas\.([a-zA-Z0-9_]+)\)$`, tt.add_alphacanvasc)
+			ctx.Step(`^write_alpha\(canvas\.([a-zA-Z0-9_]+), (\d+), (\d+), (-?\d+(?:\.\d+)?)\)$`, tt.write_alphacanvasc)
+			ctx.Step(`^alpha_at\(canvas\.([a-zA-Z0-9_]+), (-?\d+), (-?\d+)\) = (-?\d+(?:\.\d+)?)$`, tt.alpha_atcanvasc)
+			ctx.Step(`^camera\.([a-zA-Z0-9_]+)\.alpha ← (true|false)$`, tt.cameracAlpha)
+			ctx.Step(`^coverage_at\(world\.([a-zA-Z0-9_]+), ray\.([a-zA-Z0-9_]+)\) = (-?\d+(?:\.\d+)?)$`, tt.coverage_atworldwRayr)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) with (straight|premultiplied|none) alpha$`, tt.savecanvascFilesfWithAlpha)
+			ctx.Step(`^save\(canvas\.([a-zA-Z0-9_]+), files\.([a-zA-Z0-9_]+)\) with (straight|premultiplied|none) alpha and display\.([a-zA-Z0-9_]+)$`, tt.savecanvascFilesfWithAlphaAndDisplayd)
+			ctx.Step(`^the PNG files\.([a-zA-Z0-9_]+) has nrgba\((\d+), (\d+), (\d+), (\d+)\) at (\d+), (\d+)$`, tt.thePNGFilesfHasNRGBA)
+			ctx.Step(`^adaptive_sample\(camera\.([a-zA-Z0-9_]+), (\d+), (\d+), ([-0-9.]+), ([-0-9.]+)\) = adaptive_sample\(camera\.([a-zA-Z0-9_]+), (\d+), (\d+), ([-0-9.]+), ([-0-9.]+)\)$`, tt.adaptiveSamplesMatch)
+			ctx.Step(`^adaptive_sample\(camera\.([a-zA-Z0-9_]+), (\d+), (\d+), ([-0-9.]+), ([-0-9.]+)\) is off the lens centre$`, tt.adaptiveSampleIsOffTheLensCentre)
+			ctx.Step(`^bounds\.([a-zA-Z0-9_]+) holds point\((.+), (.+), (.+)\) of shapes\.([a-zA-Z0-9_]+) all through the shutter$`, tt.boundsHoldsPointAllThroughTheShutter)
+			ctx.Step(`^coordinators\.([a-zA-Z0-9_]+) ← coordinator\(camera\.([a-zA-Z0-9_]+), world\.([a-zA-Z0-9_]+)\) on loopback with a (\d+)ms tile timeout$`, tt.coordinatorsCoordinatorOnLoopbackWithATileTimeout)
+			ctx.Step(`^a worker takes a tile from coordinators\.([a-zA-Z0-9_]+) and hangs$`, tt.aWorkerTakesATileFromCoordinatorsAndHangs)
+			ctx.Step(`^saving the "([^"]+)" buffer of buffers\.([a-zA-Z0-9_]+) to files\.([a-zA-Z0-9_]+) fails$`, tt.savingTheBufferOfBuffersbToFilesfFails)
+			ctx.Step(`^canvas_from_(ppm|pfm|hdr)\(ppm\.([a-zA-Z0-9_]+)\) fails having allocated under (\d+) MB$`, tt.canvas_from_imageFailsHavingAllocatedUnder)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← encode\(canvas\.([a-zA-Z0-9_]+), "([^"]+)"\) with display\.([a-zA-Z0-9_]+)$`, tt.ppmpEncodecanvascWithDisplayd)
+			ctx.Step(`^ppm\.([a-zA-Z0-9_]+) ← the contents of files\.([a-zA-Z0-9_]+)$`, tt.ppmpTheContentsOfFilesf)
+			ctx.Step(`^the PNG files\.([a-zA-Z0-9_]+) stores canvas\.([a-zA-Z0-9_]+) as it is$`, tt.thePNGFilesfStoresCanvascAsItIs)
 		},
 		Options: &godog.Options{
 			Format:   "pretty",
@@ -960,8 +1207,8 @@ func (tt *tupletest) everyPixelOfCanvascIsColor(varName string, r, g, b float64)
 
 	for i := 0; i < a1.Width; i++ {
 		for j := 0; j < a1.Height; j++ {
-			if !a1.Pixels[i][j].Equals(b1) {
-				return fmt.Errorf("color at %d,%d is %v not %v", i, j, a1.Pixels[i][j], b1)
+			if !a1.PixelAt(i, j).Equals(b1) {
+				return fmt.Errorf("color at %d,%d is %v not %v", i, j, a1.PixelAt(i, j), b1)
 			}
 		}
 	}
@@ -980,8 +1227,8 @@ func (tt *tupletest) everyPixelOfCanvascIsColorName(varName string, varName2 str
 
 	for i := 0; i < a1.Width; i++ {
 		for j := 0; j < a1.Height; j++ {
-			if !a1.Pixels[i][j].Equals(b1) {
-				return fmt.Errorf("color at %d,%d is %v not %v", i, j, a1.Pixels[i][j], b1)
+			if !a1.PixelAt(i, j).Equals(b1) {
+				return fmt.Errorf("color at %d,%d is %v not %v", i, j, a1.PixelAt(i, j), b1)
 			}
 		}
 	}
@@ -997,10 +1244,10 @@ func (tt *tupletest) pixelAt(varName string, x, y int, varName2 string) error {
 	if !ok {
 		return fmt.Errorf("color %s not available", varName2)
 	}
-	if a1.Pixels[x][y].Equals(b1) {
+	if a1.PixelAt(x, y).Equals(b1) {
 		return nil
 	}
-	return fmt.Errorf("Pixel %d,%d is %v not %v", x, y, a1.Pixels[x][y], b1)
+	return fmt.Errorf("Pixel %d,%d is %v not %v", x, y, a1.PixelAt(x, y), b1)
 
 }
 
@@ -1024,9 +1271,7 @@ func (tt *tupletest) everyPixelOfCanvascIsSetToColor(varName string, r, g, b flo
 	}
 	cc := NewColor(r, g, b)
 	for i := range a1.Pixels {
-		for j := range a1.Pixels[i] {
-			tt.Canvases[varName].Pixels[i][j] = cc
-		}
+		a1.Pixels[i] = cc
 	}
 	return nil
 }
@@ -2607,6 +2852,35 @@ func (tt *tupletest) colorscColor_atworldwRayr(varName1, varName2, varName3 stri
 	tt.Colors[varName1] = b.ColorAt(c, maxReflects)
 	return nil
 }
+func (tt *tupletest) colorscColor_atworldwRayrFromGoroutines(varName1, varName2, varName3 string, n int) error {
+	b, ok := tt.Worlds[varName2]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName2)
+	}
+	c, ok := tt.Rays[varName3]
+	if !ok {
+		return fmt.Errorf("Ray %s not available", varName3)
+	}
+	results := make([]Color, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				results[i] = b.ColorAt(c, maxReflects)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, col := range results {
+		if !col.Equals(results[0]) {
+			return fmt.Errorf("Goroutine %d saw %v, goroutine 0 saw %v", i, col, results[0])
+		}
+	}
+	tt.Colors[varName1] = results[0]
+	return nil
+}
 func (tt *tupletest) matrixtScalingEqual(varName1, x, y, z string) error {
 	a, ok := tt.Matrices[varName1]
 	if !ok {
@@ -4454,3 +4728,2455 @@ func (tt *tupletest) shapesgObj_to_groupparsersparser(varName1, varName2 string)
 	tt.Shapes[varName1] = p.ToGroup()
 	return nil
 }
+
+func (tt *tupletest) cameracworkers(varName1 string, n int) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.Workers = n
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameractile_size(varName1 string, n int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.TileSize = n
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracHasTiles(varName1 string, n int) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	tiles := a.Tiles()
+	if len(tiles) != n {
+		return fmt.Errorf("Expected %d tiles, got %d", n, len(tiles))
+	}
+	covered := int64(0)
+	for _, t := range tiles {
+		covered += t.Width * t.Height
+	}
+	crop := a.CropWindow()
+	if covered != crop.Width*crop.Height {
+		return fmt.Errorf("Tiles cover %d pixels, not %d", covered, crop.Width*crop.Height)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasaEqualsCanvasb(varName1, varName2 string) error {
+	a, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("Canvases %s not available", varName1)
+	}
+	b, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("Canvases %s not available", varName2)
+	}
+	if a.Width != b.Width || a.Height != b.Height {
+		return fmt.Errorf("Canvas sizes differ %dx%d vs %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			if a.PixelAt(x, y) != b.PixelAt(x, y) {
+				return fmt.Errorf("Pixel %d,%d differs %v vs %v", x, y, a.PixelAt(x, y), b.PixelAt(x, y))
+			}
+			if a.AlphaAt(x, y) != b.AlphaAt(x, y) {
+				return fmt.Errorf("Alpha at %d,%d differs %v vs %v", x, y, a.AlphaAt(x, y), b.AlphaAt(x, y))
+			}
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) cameracsamples(varName1 string, n int) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.Samples = n
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracsample_mode(varName1, mode string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.SampleMode = mode
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracseed(varName1 string, seed int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.Seed = seed
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracfilterFilters(varName1, varName2 string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	f, ok := tt.Filters[varName2]
+	if !ok {
+		return fmt.Errorf("Filter %s not available", varName2)
+	}
+	a.Filter = f
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracHasSamplesForPixel(varName1 string, n int, x, y int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	if len(a.PixelSamples(x, y)) == n {
+		return nil
+	}
+	return fmt.Errorf("Expected %d samples, got %d", n, len(a.PixelSamples(x, y)))
+}
+
+func (tt *tupletest) sampleOfCameracForPixel(i int, varName1 string, x, y int64, sx, sy string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	s := a.PixelSamples(x, y)[i]
+	if epsilonEquals(s.X, StringToFloat(sx)) && epsilonEquals(s.Y, StringToFloat(sy)) {
+		return nil
+	}
+	return fmt.Errorf("Sample %d is %v", i, s)
+}
+
+func (tt *tupletest) everySampleOfCameracLiesInItsStratum(varName1 string, x, y int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	step := 1.0 / float64(a.Samples)
+	for i, s := range a.PixelSamples(x, y) {
+		col := float64(i % a.Samples)
+		row := float64(i / a.Samples)
+		if s.X < col*step || s.X >= (col+1)*step || s.Y < row*step || s.Y >= (row+1)*step {
+			return fmt.Errorf("Sample %d at %v is outside its stratum", i, s)
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) filtersfBox_filter(varName1 string) error {
+	tt.Filters[varName1] = NewBoxFilter()
+	return nil
+}
+
+func (tt *tupletest) filtersfTent_filter(varName1, r string) error {
+	tt.Filters[varName1] = NewTentFilter(StringToFloat(r))
+	return nil
+}
+
+func (tt *tupletest) filtersfGaussian_filter(varName1, r, alpha string) error {
+	tt.Filters[varName1] = NewGaussianFilter(StringToFloat(r), StringToFloat(alpha))
+	return nil
+}
+
+func (tt *tupletest) filtersfMitchell_filter(varName1, r, b, c string) error {
+	tt.Filters[varName1] = NewMitchellFilter(StringToFloat(r), StringToFloat(b), StringToFloat(c))
+	return nil
+}
+
+func (tt *tupletest) weightFiltersfEquals(varName1, dx, dy, expected string) error {
+	f, ok := tt.Filters[varName1]
+	if !ok {
+		return fmt.Errorf("Filter %s not available", varName1)
+	}
+	w := f.Weight(StringToFloat(dx), StringToFloat(dy))
+	if epsilonEquals(w, StringToFloat(expected)) {
+		return nil
+	}
+	return fmt.Errorf("%s weight is %f", f.GetFilterType(), w)
+}
+
+func (tt *tupletest) cameracadaptive_threshold(varName1, threshold string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.AdaptiveThreshold = StringToFloat(threshold)
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracadaptive_depth(varName1 string, depth int) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.AdaptiveDepth = depth
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracFiredCameraRaysAndExtraRays(varName1 string, cameraRays, extraRays int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	if a.Stats.GetCameraRays() == cameraRays && a.Stats.GetExtraRays() == extraRays {
+		return nil
+	}
+	return fmt.Errorf("Fired %d camera rays and %d extra rays", a.Stats.GetCameraRays(), a.Stats.GetExtraRays())
+}
+
+func (tt *tupletest) cameracFiredSomeExtraRays(varName1 string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	extra := a.Stats.GetExtraRays()
+	if extra > 0 && extra%5 == 0 {
+		return nil
+	}
+	return fmt.Errorf("Fired %d extra rays", extra)
+}
+
+func (tt *tupletest) cameracaperture(varName1, aperture string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.Aperture = StringToFloat(aperture)
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) cameracfocal_distance(varName1, distance string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.FocalDistance = StringToFloat(distance)
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) rayrRay_for_samplecamerac(varName1, varName2 string, x, y int64, sx, sy, lx, ly string) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	s := NewCameraSample(StringToFloat(sx), StringToFloat(sy))
+	s.LensX = StringToFloat(lx)
+	s.LensY = StringToFloat(ly)
+	tt.Rays[varName1] = a.RayForSample(x, y, s)
+	return nil
+}
+
+func (tt *tupletest) everyLensSampleOfCameracLiesOnTheUnitDisk(varName1 string, x, y int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	for i, s := range a.PixelSamples(x, y) {
+		if s.LensX*s.LensX+s.LensY*s.LensY > 1+epsilon {
+			return fmt.Errorf("Lens sample %d at %v is off the disk", i, s)
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) cameracOrthographic_camera(varName1 string, h, v int64, width, height string) error {
+	tt.Cameras[varName1] = NewOrthographicCamera(h, v, StringToFloat(width), StringToFloat(height))
+	return nil
+}
+
+func (tt *tupletest) cameracPanoramic_camera(varName1 string, h, v int64) error {
+	tt.Cameras[varName1] = NewPanoramicCamera(h, v)
+	return nil
+}
+
+func (tt *tupletest) theCubeMapOfCameracInWorldwIsRendered(varName1, varName2 string, size int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	w, ok := tt.Worlds[varName2]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName2)
+	}
+	for face, canvas := range a.RenderCubeMap(w, size) {
+		tt.Canvases[face] = canvas
+	}
+	return nil
+}
+
+func (tt *tupletest) canvascCube_map_cross(varName1 string) error {
+	faces := map[string]Canvas{}
+	for _, face := range CubeMapFaces {
+		canvas, ok := tt.Canvases[face]
+		if !ok {
+			return fmt.Errorf("Cube face %s not available", face)
+		}
+		faces[face] = canvas
+	}
+	tt.Canvases[varName1] = CubeMapCross(faces)
+	return nil
+}
+
+func (tt *tupletest) cameracFisheye_camera(varName1 string, h, v int64, fov, mapping string) error {
+	tt.Cameras[varName1] = NewFisheyeCamera(h, v, StringToFloat(fov), mapping)
+	return nil
+}
+
+func (tt *tupletest) cameracHasNoRayForPixel(varName1 string, x, y int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	if a.InView(x, y, pixelCentre) {
+		return fmt.Errorf("Pixel %d,%d is in view", x, y)
+	}
+	return nil
+}
+
+func (tt *tupletest) distortionsdBrown_conrady(varName1, k1, k2, k3, p1, p2 string) error {
+	tt.Distortions[varName1] = NewLensDistortion(StringToFloat(k1), StringToFloat(k2), StringToFloat(k3), StringToFloat(p1), StringToFloat(p2))
+	return nil
+}
+
+func (tt *tupletest) distortDistortionsd(varName1, x, y, ex, ey string) error {
+	d, ok := tt.Distortions[varName1]
+	if !ok {
+		return fmt.Errorf("Distortion %s not available", varName1)
+	}
+	dx, dy := d.Distort(StringToFloat(x), StringToFloat(y))
+	if epsilonEquals(dx, StringToFloat(ex)) && epsilonEquals(dy, StringToFloat(ey)) {
+		return nil
+	}
+	return fmt.Errorf("Distorted to (%f, %f)", dx, dy)
+}
+
+func (tt *tupletest) undistortDistortionsd(varName1, x, y, ex, ey string) error {
+	d, ok := tt.Distortions[varName1]
+	if !ok {
+		return fmt.Errorf("Distortion %s not available", varName1)
+	}
+	ux, uy := d.Undistort(StringToFloat(x), StringToFloat(y))
+	if epsilonEquals(ux, StringToFloat(ex)) && epsilonEquals(uy, StringToFloat(ey)) {
+		return nil
+	}
+	return fmt.Errorf("Undistorted to (%f, %f)", ux, uy)
+}
+
+func (tt *tupletest) undistortReversesDistort(varName1, varName2, x, y string) error {
+	d, ok := tt.Distortions[varName1]
+	if !ok {
+		return fmt.Errorf("Distortion %s not available", varName1)
+	}
+	ux, uy := d.Undistort(d.Distort(StringToFloat(x), StringToFloat(y)))
+	if epsilonEquals(ux, StringToFloat(x)) && epsilonEquals(uy, StringToFloat(y)) {
+		return nil
+	}
+	return fmt.Errorf("Round trip gave (%f, %f)", ux, uy)
+}
+
+func (tt *tupletest) cameracdistortionDistortionsd(varName1, varName2 string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	d, ok := tt.Distortions[varName2]
+	if !ok {
+		return fmt.Errorf("Distortion %s not available", varName2)
+	}
+	a.Distortion = d
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) rayrRaypointVectorAtTime(varName1 string, px, py, pz, vx, vy, vz, time string) error {
+	tt.Rays[varName1] = NewRayAt(
+		NewPoint(StringToFloat(px), StringToFloat(py), StringToFloat(pz)),
+		NewVector(StringToFloat(vx), StringToFloat(vy), StringToFloat(vz)),
+		StringToFloat(time),
+	)
+	return nil
+}
+
+func (tt *tupletest) rayrtime(varName1, time string) error {
+	r, ok := tt.Rays[varName1]
+	if !ok {
+		return fmt.Errorf("Ray %s not available", varName1)
+	}
+	if math.Abs(r.Time-StringToFloat(time)) < epsilon {
+		return nil
+	}
+	return fmt.Errorf("Ray %s time is %v, not %s", varName1, r.Time, time)
+}
+
+func (tt *tupletest) setEndTransform(varName1 string, m Matrix) error {
+	s, ok := tt.Shapes[varName1]
+	if !ok {
+		return fmt.Errorf("Shape %s not available", varName1)
+	}
+	mover, ok := s.(Mover)
+	if !ok {
+		return fmt.Errorf("Shape %s cannot move", varName1)
+	}
+	mover.SetEndTransform(m)
+	return nil
+}
+
+func (tt *tupletest) set_end_transformshapessTranslation(varName1, x, y, z string) error {
+	return tt.setEndTransform(varName1, NewTranslation(StringToFloat(x), StringToFloat(y), StringToFloat(z)))
+}
+
+func (tt *tupletest) set_end_transformshapessRotation_y(varName1, r string) error {
+	return tt.setEndTransform(varName1, NewRotationY(StringToFloat(r)))
+}
+
+func (tt *tupletest) transformAtEquals(varName1, time string, expected Matrix) error {
+	s, ok := tt.Shapes[varName1]
+	if !ok {
+		return fmt.Errorf("Shape %s not available", varName1)
+	}
+	m := TransformAt(s, StringToFloat(time))
+	if m.EqualsMatrix(expected) {
+		return nil
+	}
+	return fmt.Errorf("Transform at %s is %s, expected %s", time, m.ToString(), expected.ToString())
+}
+
+func (tt *tupletest) transform_atshapessTranslation(varName1, time, x, y, z string) error {
+	return tt.transformAtEquals(varName1, time, NewTranslation(StringToFloat(x), StringToFloat(y), StringToFloat(z)))
+}
+
+func (tt *tupletest) transform_atshapessRotation_y(varName1, time, r string) error {
+	return tt.transformAtEquals(varName1, time, NewRotationY(StringToFloat(r)))
+}
+
+func (tt *tupletest) boundsbBoundsshapess(varName1, varName2 string) error {
+	s, ok := tt.Shapes[varName2]
+	if !ok {
+		return fmt.Errorf("Shape %s not available", varName2)
+	}
+	tt.Bounds[varName1] = *s.Bounds()
+	return nil
+}
+
+func (tt *tupletest) boundsbminpointPoint(varName1, x, y, z string) error {
+	b, ok := tt.Bounds[varName1]
+	if !ok {
+		return fmt.Errorf("Bounds %s not available", varName1)
+	}
+	if b.Minimum.EqualsTuple(NewPoint(StringToFloat(x), StringToFloat(y), StringToFloat(z))) {
+		return nil
+	}
+	return fmt.Errorf("Bounds %s minimum is %v", varName1, b.Minimum)
+}
+
+func (tt *tupletest) boundsbmaxpointPoint(varName1, x, y, z string) error {
+	b, ok := tt.Bounds[varName1]
+	if !ok {
+		return fmt.Errorf("Bounds %s not available", varName1)
+	}
+	if b.Maximum.EqualsTuple(NewPoint(StringToFloat(x), StringToFloat(y), StringToFloat(z))) {
+		return nil
+	}
+	return fmt.Errorf("Bounds %s maximum is %v", varName1, b.Maximum)
+}
+
+func (tt *tupletest) cameracshutter(varName1, open, close string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.ShutterOpen = StringToFloat(open)
+	a.ShutterClose = StringToFloat(close)
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) rayrRay_for_samplecameracTime(varName1, varName2 string, x, y int64, sx, sy, time string) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	s := NewCameraSample(StringToFloat(sx), StringToFloat(sy))
+	s.Time = StringToFloat(time)
+	tt.Rays[varName1] = a.RayForSample(x, y, s)
+	return nil
+}
+
+func (tt *tupletest) cameracSampleTimesCoverTheShutter(varName1 string, x, y int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	samples := a.PixelSamples(x, y)
+	strata := map[int]bool{}
+	for _, s := range samples {
+		if s.Time < 0 || s.Time >= 1 {
+			return fmt.Errorf("Sample time %v is outside the shutter", s.Time)
+		}
+		strata[int(s.Time*float64(len(samples)))] = true
+	}
+	if len(strata) != len(samples) {
+		return fmt.Errorf("%d samples only cover %d shutter strata", len(samples), len(strata))
+	}
+	return nil
+}
+
+func (tt *tupletest) cameraccrop(varName1 string, x, y, w, h int64) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	a.Crop = &Tile{X: x, Y: y, Width: w, Height: h}
+	tt.Cameras[varName1] = a
+	return nil
+}
+
+func (tt *tupletest) render_intocameracWorldwCanvasc(varName1, varName2, varName3 string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	w, ok := tt.Worlds[varName2]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName2)
+	}
+	image, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("Canvas %s not available", varName3)
+	}
+	a.RenderInto(w, &image)
+	tt.Canvases[varName3] = image
+	return nil
+}
+
+func (tt *tupletest) filescATemporaryCheckpoint(varName1 string) error {
+	fname, err := ioutil.TempFile(os.TempDir(), "checkpoint")
+	if err != nil {
+		return err
+	}
+	fname.Close()
+	os.Remove(fname.Name())
+	tt.Files[varName1] = fname.Name()
+	return nil
+}
+
+func (tt *tupletest) canvasimageRender_with_checkpoint(varName1, varName2, varName3, varName4 string) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName3)
+	}
+	f, ok := tt.Files[varName4]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName4)
+	}
+	image, err := a.RenderWithCheckpoint(w, f)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = image
+	return nil
+}
+
+func (tt *tupletest) filescLosesItsLastBytes(varName1 string, n int64) error {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName1)
+	}
+	info, err := os.Stat(f)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(f, info.Size()-n)
+}
+
+func (tt *tupletest) loadCheckpoint(varName1, varName2, varName3 string) (*Checkpoint, error) {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return nil, fmt.Errorf("File %s not available", varName1)
+	}
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return nil, fmt.Errorf("Camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return nil, fmt.Errorf("World %s not available", varName3)
+	}
+	return LoadCheckpoint(f, SceneHash(w, a))
+}
+
+func (tt *tupletest) load_checkpointIsRejected(varName1, varName2, varName3 string) error {
+	_, err := tt.loadCheckpoint(varName1, varName2, varName3)
+	if err == ErrCheckpointMismatch {
+		return nil
+	}
+	return fmt.Errorf("Expected the checkpoint to be rejected, got %v", err)
+}
+
+func (tt *tupletest) load_checkpointHasTiles(varName1, varName2, varName3 string, n int) error {
+	cp, err := tt.loadCheckpoint(varName1, varName2, varName3)
+	if err != nil {
+		return err
+	}
+	if len(cp.Tiles) == n {
+		return nil
+	}
+	return fmt.Errorf("Expected %d tiles in the checkpoint, got %d", n, len(cp.Tiles))
+}
+
+func (tt *tupletest) renderWithProgress(varName1, varName2, varName3 string, cancelAfter int) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName3)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cancelAfter == 0 {
+		cancel()
+	}
+	reports := []Progress{}
+	image, err := a.RenderContext(ctx, w, func(p Progress) {
+		reports = append(reports, p)
+		if p.TilesDone == cancelAfter {
+			cancel()
+		}
+	})
+	tt.Canvases[varName1] = image
+	tt.Progresses[varName2] = reports
+	tt.Errors[varName1] = err
+	return nil
+}
+
+func (tt *tupletest) canvasimageRender_with_progress(varName1, varName2, varName3 string) error {
+	return tt.renderWithProgress(varName1, varName2, varName3, -1)
+}
+
+func (tt *tupletest) canvasimageRender_with_progressCancelledAfter(varName1, varName2, varName3 string, n int) error {
+	return tt.renderWithProgress(varName1, varName2, varName3, n)
+}
+
+func (tt *tupletest) cameracReportedProgressTimes(varName1 string, n int) error {
+	reports := tt.Progresses[varName1]
+	if len(reports) == n {
+		return nil
+	}
+	return fmt.Errorf("Expected %d progress reports, got %d", n, len(reports))
+}
+
+func (tt *tupletest) theLastProgressOfCamerac(varName1 string, tiles, tilesTotal int, pixels, pixelsTotal int64) error {
+	reports := tt.Progresses[varName1]
+	if len(reports) == 0 {
+		return fmt.Errorf("Camera %s reported no progress", varName1)
+	}
+	p := reports[len(reports)-1]
+	if p.TilesDone != tiles || p.TilesTotal != tilesTotal || p.PixelsDone != pixels || p.PixelsTotal != pixelsTotal {
+		return fmt.Errorf("Last progress was %+v", p)
+	}
+	if p.PixelsDone == p.PixelsTotal && p.Remaining != 0 {
+		return fmt.Errorf("Finished render still has %v remaining", p.Remaining)
+	}
+	return nil
+}
+
+func (tt *tupletest) theProgressOfCameracNeverGoesBackwards(varName1 string) error {
+	reports := tt.Progresses[varName1]
+	for i := 1; i < len(reports); i++ {
+		if reports[i].TilesDone <= reports[i-1].TilesDone || reports[i].Elapsed < reports[i-1].Elapsed {
+			return fmt.Errorf("Progress went from %+v to %+v", reports[i-1], reports[i])
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) theRenderOfCanvascWasCancelled(varName1 string) error {
+	if tt.Errors[varName1] == context.Canceled {
+		return nil
+	}
+	return fmt.Errorf("Expected the render to be cancelled, got %v", tt.Errors[varName1])
+}
+
+// servedRender is a coordinator running in the background of a scenario.
+type servedRender struct {
+	Addr  string
+	Done  chan struct{}
+	Image Canvas
+	Err   error
+}
+
+func (tt *tupletest) canvasimageRenderAfterASceneRoundTrip(varName1, varName2, varName3 string) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName3)
+	}
+	w2, a2, err := CopyScene(w, a)
+	if err != nil {
+		return err
+	}
+	a2.TileSize = a.TileSize
+	tt.Canvases[varName1] = a2.Render(w2)
+	return nil
+}
+
+func (tt *tupletest) describingCameracAndWorldwFails(varName1, varName2 string) error {
+	a, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	w, ok := tt.Worlds[varName2]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName2)
+	}
+	if _, err := DescribeScene(w, a); err == nil {
+		return fmt.Errorf("Expected describing the scene to fail")
+	}
+	return nil
+}
+
+func (tt *tupletest) coordinatorsCoordinatorOnLoopback(varName1, varName2, varName3 string) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName3)
+	}
+	co, err := NewCoordinator(w, a)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	served := &servedRender{Addr: l.Addr().String(), Done: make(chan struct{})}
+	go func() {
+		defer close(served.Done)
+		served.Image, served.Err = co.Serve(context.Background(), l)
+	}()
+	tt.Coordinators[varName1] = served
+	return nil
+}
+
+func (tt *tupletest) workersJoinCoordinators(n int, varName1 string) error {
+	served, ok := tt.Coordinators[varName1]
+	if !ok {
+		return fmt.Errorf("Coordinator %s not available", varName1)
+	}
+	for i := 0; i < n; i++ {
+		go DialWorker(context.Background(), served.Addr)
+	}
+	return nil
+}
+
+func (tt *tupletest) aWorkerTakesATileFromCoordinatorsAndDies(varName1 string) error {
+	served, ok := tt.Coordinators[varName1]
+	if !ok {
+		return fmt.Errorf("Coordinator %s not available", varName1)
+	}
+	conn, err := net.Dial("tcp", served.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	dec := gob.NewDecoder(conn)
+	if err := dec.Decode(&SceneDescription{}); err != nil {
+		return err
+	}
+	return dec.Decode(&tileJob{})
+}
+
+func (tt *tupletest) canvasimageTheRenderFromCoordinators(varName1, varName2 string) error {
+	served, ok := tt.Coordinators[varName2]
+	if !ok {
+		return fmt.Errorf("Coordinator %s not available", varName2)
+	}
+	select {
+	case <-served.Done:
+	case <-time.After(time.Minute):
+		return fmt.Errorf("Coordinator %s never finished", varName2)
+	}
+	tt.Canvases[varName1] = served.Image
+	return served.Err
+}
+
+func (tt *tupletest) stereorStereo_rig(varName1, varName2, interocular, convergence string) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	tt.Stereos[varName1] = NewStereoRig(a, StringToFloat(interocular), StringToFloat(convergence))
+	return nil
+}
+
+func (tt *tupletest) cameraeTheEyeOfStereor(varName1, side, varName2 string) error {
+	r, ok := tt.Stereos[varName2]
+	if !ok {
+		return fmt.Errorf("Stereo rig %s not available", varName2)
+	}
+	tt.Cameras[varName1] = r.Eye(side)
+	return nil
+}
+
+func (tt *tupletest) theCentreRaysOfStereorCrossAtPoint(varName1, x, y, z string) error {
+	r, ok := tt.Stereos[varName1]
+	if !ok {
+		return fmt.Errorf("Stereo rig %s not available", varName1)
+	}
+	expected := NewPoint(StringToFloat(x), StringToFloat(y), StringToFloat(z))
+	for _, side := range []string{"left", "right"} {
+		eye := r.Eye(side)
+		ray := eye.RayForPixel(eye.HSize/2, eye.VSize/2)
+		p := ray.Position((expected.Z - ray.Origin.Z) / ray.Direction.Z)
+		if !p.EqualsTuple(expected) {
+			return fmt.Errorf("The %s eye's centre ray passes %v", side, p)
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) canvascCompose_stereo(varName1, varName2, varName3, layout string) error {
+	left, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("Canvas %s not available", varName2)
+	}
+	right, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("Canvas %s not available", varName3)
+	}
+	out, err := ComposeStereo(left, right, layout)
+	tt.Canvases[varName1] = out
+	return err
+}
+
+func (tt *tupletest) compose_stereoFails(varName1, varName2, layout string) error {
+	if err := tt.canvascCompose_stereo("failed", varName1, varName2, layout); err == nil {
+		return fmt.Errorf("Expected composing %s to fail", layout)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvascRender_stereo(varName1, varName2, varName3, layout string) error {
+	r, ok := tt.Stereos[varName2]
+	if !ok {
+		return fmt.Errorf("Stereo rig %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName3)
+	}
+	out, err := r.RenderLayout(w, layout)
+	tt.Canvases[varName1] = out
+	return err
+}
+
+func (tt *tupletest) ppmWrite_ppmcanvasc(varName1, varName2 string) error {
+	a, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	out := bytes.Buffer{}
+	if err := a.WritePPM(&out); err != nil {
+		return err
+	}
+	tt.PPMs[varName1] = out.String()
+	return nil
+}
+
+func (tt *tupletest) ppmaEqualsPpmb(varName1, varName2 string) error {
+	if tt.PPMs[varName1] == tt.PPMs[varName2] {
+		return nil
+	}
+	return fmt.Errorf("ppm %s is\n%s\nnot\n%s", varName1, tt.PPMs[varName1], tt.PPMs[varName2])
+}
+
+func (tt *tupletest) canvasrRegioncanvasc(varName1, varName2 string, x, y, w, h int) error {
+	a, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	tt.Canvases[varName1] = a.Region(x, y, w, h)
+	return nil
+}
+
+func (tt *tupletest) set_regioncanvascCanvasr(varName1 string, x, y int, varName2 string) error {
+	a, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	b, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	a.SetRegion(x, y, b)
+	return nil
+}
+
+func (tt *tupletest) rowOfCanvascHasPixels(y int, varName1 string, n int) error {
+	a, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	if len(a.Row(y)) == n {
+		return nil
+	}
+	return fmt.Errorf("row %d has %d pixels", y, len(a.Row(y)))
+}
+
+func (tt *tupletest) pixelOfRowOfCanvascEqualsColors(x, y int, varName1, varName2 string) error {
+	a, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	b, ok := tt.Colors[varName2]
+	if !ok {
+		return fmt.Errorf("color %s not available", varName2)
+	}
+	if a.Row(y)[x].Equals(b) {
+		return nil
+	}
+	return fmt.Errorf("pixel %d of row %d is %v", x, y, a.Row(y)[x])
+}
+
+func (tt *tupletest) filesfATemporaryFile(varName1, name string) error {
+	dir, err := ioutil.TempDir(os.TempDir(), "image")
+	if err != nil {
+		return err
+	}
+	tt.Files[varName1] = filepath.Join(dir, name)
+	return nil
+}
+
+func (tt *tupletest) savecanvascFilesfPNGDepth(varName1, varName2 string, depth int) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	opts := NewEncodeOptions()
+	opts.PNGDepth = depth
+	return c.SaveWith(f, opts)
+}
+
+func (tt *tupletest) savecanvascFilesf(varName1, varName2 string) error {
+	return tt.savecanvascFilesfPNGDepth(varName1, varName2, 8)
+}
+
+func (tt *tupletest) canvascLoadfilesf(varName1, varName2 string) error {
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	c, err := LoadCanvas(f)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) filesfStartsWithTheBytes(varName1, prefix string) error {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName1)
+	}
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(fmt.Sprintf("%x", data), prefix) {
+		return fmt.Errorf("%s does not start with %s", f, prefix)
+	}
+	return nil
+}
+
+func (tt *tupletest) image_formatEquals(path, format string) error {
+	got, err := ImageFormat(path)
+	if err != nil {
+		return err
+	}
+	if got != format {
+		return fmt.Errorf("format of %s is %s, not %s", path, got, format)
+	}
+	return nil
+}
+
+func (tt *tupletest) image_formatFails(path string) error {
+	if got, err := ImageFormat(path); err == nil {
+		return fmt.Errorf("format of %s is %s, expected an error", path, got)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasaMatchesCanvasbToWithin(varName1, varName2 string, tolerance float64) error {
+	a, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	b, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	if a.Width != b.Width || a.Height != b.Height {
+		return fmt.Errorf("Canvas sizes differ %dx%d vs %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	for i, p := range a.Pixels {
+		q := b.Pixels[i]
+		if math.Abs(p.Red-q.Red) > tolerance || math.Abs(p.Green-q.Green) > tolerance || math.Abs(p.Blue-q.Blue) > tolerance {
+			return fmt.Errorf("Pixel %d,%d differs %v vs %v", i%a.Width, i/a.Width, p, q)
+		}
+		x, y := i%a.Width, i/a.Width
+		if math.Abs(a.AlphaAt(x, y)-b.AlphaAt(x, y)) > tolerance {
+			return fmt.Errorf("Alpha at %d,%d differs %v vs %v", x, y, a.AlphaAt(x, y), b.AlphaAt(x, y))
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) canvascCanvas_from_image(varName1 string, w, h, x0, y0 int, varName2 string, x, y int) error {
+	col, ok := tt.Colors[varName2]
+	if !ok {
+		return fmt.Errorf("color %s not available", varName2)
+	}
+	img := image.NewRGBA(image.Rect(x0, y0, x0+w, y0+h))
+	img.Set(x, y, color.RGBA{
+		R: uint8(colorToDepth(col.Red, 255)),
+		G: uint8(colorToDepth(col.Green, 255)),
+		B: uint8(colorToDepth(col.Blue, 255)),
+		A: 255,
+	})
+	tt.Canvases[varName1] = NewCanvasFromImage(img)
+	return nil
+}
+
+func (tt *tupletest) drawcanvasaCanvasb(varName1, varName2 string) error {
+	a, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	b, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	draw.Draw(&a, a.Bounds(), &b, image.Point{}, draw.Src)
+	return nil
+}
+
+func (tt *tupletest) ppmpAFileContaining(varName1 string, arg1 *godog.DocString) error {
+	tt.PPMs[varName1] = arg1.Content
+	return nil
+}
+
+func (tt *tupletest) ppmpAP6FileWithHeaderAndTheBytes(varName1, header, raster string) error {
+	data, err := hex.DecodeString(raster)
+	if err != nil {
+		return err
+	}
+	tt.PPMs[varName1] = strings.ReplaceAll(header, `\n`, "\n") + "\n" + string(data)
+	return nil
+}
+
+func (tt *tupletest) canvascCanvas_from_ppmppmp(varName1, varName2 string) error {
+	p, ok := tt.PPMs[varName2]
+	if !ok {
+		return fmt.Errorf("ppm %s not available", varName2)
+	}
+	c, err := ReadPPM(strings.NewReader(p))
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) canvas_from_ppmFailsOnLine(varName1 string, line int) error {
+	p, ok := tt.PPMs[varName1]
+	if !ok {
+		return fmt.Errorf("ppm %s not available", varName1)
+	}
+	_, err := ReadPPM(strings.NewReader(p))
+	perr := &ImageFileError{}
+	if !errors.As(err, &perr) {
+		return fmt.Errorf("expected an ImageFileError, got %v", err)
+	}
+	if perr.Line != line {
+		return fmt.Errorf("error %q is not on line %d", perr, line)
+	}
+	return nil
+}
+
+func (tt *tupletest) ppmpWrite_p6canvasc(varName1, varName2 string) error {
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	out := strings.Builder{}
+	if err := c.WriteP6(&out); err != nil {
+		return err
+	}
+	tt.PPMs[varName1] = out.String()
+	return nil
+}
+
+func (tt *tupletest) ppmpHasBytes(varName1 string, n int) error {
+	p, ok := tt.PPMs[varName1]
+	if !ok {
+		return fmt.Errorf("ppm %s not available", varName1)
+	}
+	if len(p) != n {
+		return fmt.Errorf("ppm %s has %d bytes, not %d", varName1, len(p), n)
+	}
+	return nil
+}
+
+func (tt *tupletest) savecanvascFilesfBinaryPPM(varName1, varName2 string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	opts := NewEncodeOptions()
+	opts.PPMBinary = true
+	return c.SaveWith(f, opts)
+}
+
+func readFloatImage(format, data string) (Canvas, error) {
+	if format == "pfm" {
+		return ReadPFM(strings.NewReader(data))
+	}
+	return ReadHDR(strings.NewReader(data))
+}
+
+func (tt *tupletest) canvascCanvas_from_hdrppmp(varName1, format, varName2 string) error {
+	p, ok := tt.PPMs[varName2]
+	if !ok {
+		return fmt.Errorf("ppm %s not available", varName2)
+	}
+	c, err := readFloatImage(format, p)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) canvas_from_hdrFailsOnLine(format, varName1 string, line int) error {
+	p, ok := tt.PPMs[varName1]
+	if !ok {
+		return fmt.Errorf("ppm %s not available", varName1)
+	}
+	_, err := readFloatImage(format, p)
+	ferr := &ImageFileError{}
+	if !errors.As(err, &ferr) {
+		return fmt.Errorf("expected an ImageFileError, got %v", err)
+	}
+	if ferr.Line != line {
+		return fmt.Errorf("error %q is not on line %d", ferr, line)
+	}
+	return nil
+}
+
+func (tt *tupletest) filesfHasBytes(varName1 string, n int64) error {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName1)
+	}
+	info, err := os.Stat(f)
+	if err != nil {
+		return err
+	}
+	if info.Size() != n {
+		return fmt.Errorf("%s has %d bytes, not %d", f, info.Size(), n)
+	}
+	return nil
+}
+
+func (tt *tupletest) displaydDisplay_transform(varName1 string) error {
+	tt.Displays[varName1] = NewDisplayTransform()
+	return nil
+}
+
+func (tt *tupletest) displaydExposure(varName1 string, stops float64) error {
+	d, ok := tt.Displays[varName1]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName1)
+	}
+	d.Exposure = stops
+	tt.Displays[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) displaydAuto_exposure(varName1, on string) error {
+	d, ok := tt.Displays[varName1]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName1)
+	}
+	d.AutoExposure = on == "true"
+	tt.Displays[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) displaydTone_map(varName1, toneMap string) error {
+	d, ok := tt.Displays[varName1]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName1)
+	}
+	d.ToneMap = toneMap
+	tt.Displays[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) displaydSrgb(varName1, on string) error {
+	d, ok := tt.Displays[varName1]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName1)
+	}
+	d.SRGB = on == "true"
+	tt.Displays[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) canvasoApplydisplaydCanvasc(varName1, varName2, varName3 string) error {
+	d, ok := tt.Displays[varName2]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName2)
+	}
+	c, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName3)
+	}
+	tt.Canvases[varName1] = d.Apply(&c)
+	return nil
+}
+
+func (tt *tupletest) savecanvascFilesfWithDisplayd(varName1, varName2, varName3 string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	d, ok := tt.Displays[varName3]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName3)
+	}
+	opts := NewEncodeOptions()
+	opts.Display = d
+	return c.SaveWith(f, opts)
+}
+
+func (tt *tupletest) ppmpEncodecanvasc(varName1, varName2, format string) error {
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	out := strings.Builder{}
+	if err := c.Encode(&out, format, NewEncodeOptions()); err != nil {
+		return err
+	}
+	tt.PPMs[varName1] = out.String()
+	return nil
+}
+
+func (tt *tupletest) log_average_luminanceEquals(varName1 string, expected float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	if got := LogAverageLuminance(&c); !epsilonEquals(got, expected) {
+		return fmt.Errorf("log average luminance is %v, not %v", got, expected)
+	}
+	return nil
+}
+
+func (tt *tupletest) aovaAov_atworldwRayr(varName1, varName2, varName3 string) error {
+	w, ok := tt.Worlds[varName2]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName2)
+	}
+	r, ok := tt.Rays[varName3]
+	if !ok {
+		return fmt.Errorf("Ray %s not available", varName3)
+	}
+	tt.AOVSamples[varName1] = w.AOVAt(r)
+	return nil
+}
+
+func (tt *tupletest) aovaFlagIs(varName1, flag, expected string) error {
+	s, ok := tt.AOVSamples[varName1]
+	if !ok {
+		return fmt.Errorf("AOV sample %s not available", varName1)
+	}
+	got := s.Hit
+	if flag == "shadowed" {
+		got = s.Shadowed
+	}
+	if got != (expected == "true") {
+		return fmt.Errorf("%s of %s is %v, not %s", flag, varName1, got, expected)
+	}
+	return nil
+}
+
+func (tt *tupletest) aovaDepth(varName1 string, expected float64) error {
+	s, ok := tt.AOVSamples[varName1]
+	if !ok {
+		return fmt.Errorf("AOV sample %s not available", varName1)
+	}
+	if !epsilonEquals(s.Depth, expected) {
+		return fmt.Errorf("depth of %s is %v, not %v", varName1, s.Depth, expected)
+	}
+	return nil
+}
+
+func (tt *tupletest) aovaNormal(varName1 string, x, y, z float64) error {
+	s, ok := tt.AOVSamples[varName1]
+	if !ok {
+		return fmt.Errorf("AOV sample %s not available", varName1)
+	}
+	if !s.Normal.EqualsTuple(NewVector(x, y, z)) {
+		return fmt.Errorf("normal of %s is %v", varName1, s.Normal)
+	}
+	return nil
+}
+
+func (tt *tupletest) aovaAlbedo(varName1 string, r, g, b float64) error {
+	s, ok := tt.AOVSamples[varName1]
+	if !ok {
+		return fmt.Errorf("AOV sample %s not available", varName1)
+	}
+	if !s.Albedo.Equals(NewColor(r, g, b)) {
+		return fmt.Errorf("albedo of %s is %v", varName1, s.Albedo)
+	}
+	return nil
+}
+
+func (tt *tupletest) aovaObject_idIsTheIdOfSpheres(varName1, varName2 string) error {
+	s, ok := tt.AOVSamples[varName1]
+	if !ok {
+		return fmt.Errorf("AOV sample %s not available", varName1)
+	}
+	shape, ok := tt.Shapes[varName2]
+	if !ok {
+		return fmt.Errorf("Shape %s not available", varName2)
+	}
+	if s.ObjectID != shape.GetID() {
+		return fmt.Errorf("object id of %s is %d, not %d", varName1, s.ObjectID, shape.GetID())
+	}
+	return nil
+}
+
+func (tt *tupletest) renderWithBuffers(varName1, varName2, varName3, names string) (Canvas, AOVBuffers, error) {
+	c, ok := tt.Cameras[varName1]
+	if !ok {
+		return Canvas{}, nil, fmt.Errorf("Camera %s not available", varName1)
+	}
+	w, ok := tt.Worlds[varName2]
+	if !ok {
+		return Canvas{}, nil, fmt.Errorf("World %s not available", varName2)
+	}
+	image, buffers, err := c.RenderWithAOVs(w, strings.Split(names, ",")...)
+	if err == nil {
+		tt.AOVBuffers[varName3] = buffers
+	}
+	return image, buffers, err
+}
+
+func (tt *tupletest) canvasimageRenderWithBuffers(varName1, varName2, varName3, varName4, names string) error {
+	image, _, err := tt.renderWithBuffers(varName2, varName3, varName4, names)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = image
+	return nil
+}
+
+func (tt *tupletest) renderWithBuffersFails(varName1, varName2, varName3, names string) error {
+	if _, _, err := tt.renderWithBuffers(varName1, varName2, varName3, names); err == nil {
+		return fmt.Errorf("rendering buffers %q should fail", names)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasdTheBufferOfBuffersb(varName1, name, kind, varName2 string) error {
+	b, ok := tt.AOVBuffers[varName2]
+	if !ok {
+		return fmt.Errorf("AOV buffers %s not available", varName2)
+	}
+	if kind == "image" {
+		img, err := b.Image(name)
+		if err != nil {
+			return err
+		}
+		tt.Canvases[varName1] = img
+		return nil
+	}
+	c, ok := b[name]
+	if !ok {
+		return fmt.Errorf("no %s buffer in %s", name, varName2)
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) buffersbHasNoBuffer(varName1, name string) error {
+	b, ok := tt.AOVBuffers[varName1]
+	if !ok {
+		return fmt.Errorf("AOV buffers %s not available", varName1)
+	}
+	if _, ok := b[name]; ok {
+		return fmt.Errorf("%s has a %s buffer", varName1, name)
+	}
+	return nil
+}
+
+func (tt *tupletest) saveTheBufferOfBuffersbToFilesf(name, varName1, varName2 string) error {
+	b, ok := tt.AOVBuffers[varName1]
+	if !ok {
+		return fmt.Errorf("AOV buffers %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	return b.Save(name, f)
+}
+
+func (tt *tupletest) diffdComparecanvasaCanvasb(varName1, varName2, varName3 string) error {
+	a, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	b, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName3)
+	}
+	d, err := CompareCanvases(a, b)
+	if err != nil {
+		return err
+	}
+	tt.ImageDiffs[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) compareFails(varName1, varName2 string) error {
+	if err := tt.diffdComparecanvasaCanvasb("failed", varName1, varName2); err == nil {
+		return fmt.Errorf("comparing %s and %s should fail", varName1, varName2)
+	}
+	return nil
+}
+
+func (tt *tupletest) imageDiffMetric(varName1, metric string) (float64, error) {
+	d, ok := tt.ImageDiffs[varName1]
+	if !ok {
+		return 0, fmt.Errorf("diff %s not available", varName1)
+	}
+	switch metric {
+	case "max_error":
+		return d.MaxError, nil
+	case "mean_error":
+		return d.MeanError, nil
+	case "psnr":
+		return d.PSNR, nil
+	}
+	return d.SSIM, nil
+}
+
+func (tt *tupletest) diffdMetricEquals(varName1, metric string, expected float64) error {
+	got, err := tt.imageDiffMetric(varName1, metric)
+	if err != nil {
+		return err
+	}
+	if !epsilonEquals(got, expected) {
+		return fmt.Errorf("%s of %s is %v, not %v", metric, varName1, got, expected)
+	}
+	return nil
+}
+
+func (tt *tupletest) diffdMetricCompares(varName1, metric, op string, bound float64) error {
+	got, err := tt.imageDiffMetric(varName1, metric)
+	if err != nil {
+		return err
+	}
+	if (op == "<" && got >= bound) || (op == ">" && got <= bound) {
+		return fmt.Errorf("%s of %s is %v, not %s %v", metric, varName1, got, op, bound)
+	}
+	return nil
+}
+
+func (tt *tupletest) diffdPsnrIsInfinite(varName1 string) error {
+	got, err := tt.imageDiffMetric(varName1, "psnr")
+	if err != nil {
+		return err
+	}
+	if !math.IsInf(got, 1) {
+		return fmt.Errorf("psnr of %s is %v", varName1, got)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasdDiff_image(varName1, varName2, varName3 string, threshold float64) error {
+	a, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	b, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName3)
+	}
+	d, err := DiffImage(a, b, threshold)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) canvascACheckerboard(varName1 string, w, h int, r1, g1, b1, r2, g2, b2 float64) error {
+	c := NewCanvas(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+y)%2 == 0 {
+				c.WritePixel(x, y, NewColor(r1, g1, b1))
+			} else {
+				c.WritePixel(x, y, NewColor(r2, g2, b2))
+			}
+		}
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) checkGolden(varName1, varName2 string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	_, err := CheckGolden(c, f, NewGoldenTolerance(), false)
+	return err
+}
+
+func (tt *tupletest) check_goldencanvascFilesf(varName1, varName2 string) error {
+	return tt.checkGolden(varName1, varName2)
+}
+
+func (tt *tupletest) check_goldenFailsWithADiffImage(varName1, varName2 string) error {
+	err := tt.checkGolden(varName1, varName2)
+	if err == nil {
+		return fmt.Errorf("%s should not match its reference", varName1)
+	}
+	m := regexp.MustCompile(`see (.+-diff\.png)$`).FindStringSubmatch(err.Error())
+	if m == nil {
+		return fmt.Errorf("no diff image in %q", err)
+	}
+	if _, err := os.Stat(m[1]); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tt *tupletest) check_goldenFailsWithoutReference(varName1, varName2 string) error {
+	err := tt.checkGolden(varName1, varName2)
+	if err == nil || !strings.Contains(err.Error(), "no reference image") {
+		return fmt.Errorf("expected a missing reference error, got %v", err)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvascRender_chapter(varName1, chapter string, hsize, vsize int64) error {
+	c, err := RenderChapter(chapter, hsize, vsize)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+// canvascMatchesTheGoldenImage compares against a reference under
+// features; set GOLDEN_UPDATE=1 to rewrite the references instead.
+func (tt *tupletest) canvascMatchesTheGoldenImage(varName1, path string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	_, err := CheckGolden(c, filepath.Join("features", path), NewGoldenTolerance(), os.Getenv("GOLDEN_UPDATE") != "")
+	return err
+}
+
+func (tt *tupletest) postpPost_process(varName1, spec string) error {
+	p, err := ParsePostProcess(spec)
+	if err != nil {
+		return err
+	}
+	tt.PostProcesses[varName1] = p
+	return nil
+}
+
+func (tt *tupletest) post_processFails(spec string) error {
+	if _, err := ParsePostProcess(spec); err == nil {
+		return fmt.Errorf("%q should not parse", spec)
+	}
+	return nil
+}
+
+func (tt *tupletest) theEffectsOfPostp(varName1, names string) error {
+	p, ok := tt.PostProcesses[varName1]
+	if !ok {
+		return fmt.Errorf("post process %s not available", varName1)
+	}
+	got := []string{}
+	for _, e := range p {
+		got = append(got, e.GetEffectType())
+	}
+	if strings.Join(got, ",") != names {
+		return fmt.Errorf("effects are %q, not %q", strings.Join(got, ","), names)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasoApplypostpCanvasc(varName1, varName2, varName3 string) error {
+	p, ok := tt.PostProcesses[varName2]
+	if !ok {
+		return fmt.Errorf("post process %s not available", varName2)
+	}
+	c, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName3)
+	}
+	tt.Canvases[varName1] = p.Apply(&c)
+	return nil
+}
+
+func (tt *tupletest) savecanvascFilesfWithPostp(varName1, varName2, varName3 string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	p, ok := tt.PostProcesses[varName3]
+	if !ok {
+		return fmt.Errorf("post process %s not available", varName3)
+	}
+	opts := NewEncodeOptions()
+	opts.PostProcess = p
+	return c.SaveWith(f, opts)
+}
+
+func (tt *tupletest) theTotalOfCanvasc(varName1 string, r, g, b float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	total := NewColor(0, 0, 0)
+	for _, col := range c.Pixels {
+		total = total.Add(col)
+	}
+	if !total.Equals(NewColor(r, g, b)) {
+		return fmt.Errorf("total of %s is %v, not %v", varName1, total, NewColor(r, g, b))
+	}
+	return nil
+}
+
+func (tt *tupletest) animaAnimation(varName1 string) error {
+	a := NewAnimation()
+	tt.Animations[varName1] = &a
+	return nil
+}
+
+func (tt *tupletest) add_frameanimaCanvasc(varName1, varName2 string) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	a.AddFrame(c)
+	return nil
+}
+
+func (tt *tupletest) animaSetting(varName1, setting string, value int) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	switch setting {
+	case "delay":
+		a.Delay = value
+	case "loop_count":
+		a.LoopCount = value
+	case "palette_size":
+		a.PaletteSize = value
+	}
+	return nil
+}
+
+func (tt *tupletest) animaDither(varName1, on string) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	a.Dither = on == "true"
+	return nil
+}
+
+func (tt *tupletest) animaPalette(varName1, colors string) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	a.Palette = color.Palette{}
+	for _, h := range strings.Split(colors, ",") {
+		rgb, err := hex.DecodeString(h)
+		if err != nil || len(rgb) != 3 {
+			return fmt.Errorf("%q is not a colour", h)
+		}
+		a.Palette = append(a.Palette, color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255})
+	}
+	return nil
+}
+
+func (tt *tupletest) animaEncodeDisplay(varName1, varName2 string) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	d, ok := tt.Displays[varName2]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName2)
+	}
+	a.Encode.Display = d
+	return nil
+}
+
+func (tt *tupletest) animaRender_turntable(varName1, varName2, varName3 string, frames int) error {
+	c, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("world %s not available", varName3)
+	}
+	a := RenderTurntable(w, c, frames)
+	tt.Animations[varName1] = &a
+	return nil
+}
+
+func (tt *tupletest) animaHasFrames(varName1 string, n int) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	if len(a.Frames) != n {
+		return fmt.Errorf("animation has %d frames, not %d", len(a.Frames), n)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasdFrameOfAnima(varName1 string, n int, varName2 string) error {
+	a, ok := tt.Animations[varName2]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName2)
+	}
+	if n < 1 || n > len(a.Frames) {
+		return fmt.Errorf("animation has no frame %d", n)
+	}
+	tt.Canvases[varName1] = a.Frames[n-1]
+	return nil
+}
+
+func (tt *tupletest) save_framesanimaFilesf(varName1, varName2 string) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	_, err := a.SaveFrames(f)
+	return err
+}
+
+func (tt *tupletest) save_framesFails(varName1, varName2 string) error {
+	if err := tt.save_framesanimaFilesf(varName1, varName2); err == nil {
+		return fmt.Errorf("saving the frames should fail")
+	}
+	return nil
+}
+
+func (tt *tupletest) theDirectoryOfFilesfHolds(varName1, names string) error {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName1)
+	}
+	entries, err := os.ReadDir(filepath.Dir(f))
+	if err != nil {
+		return err
+	}
+	got := []string{}
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if strings.Join(got, ",") != names {
+		return fmt.Errorf("directory holds %q, not %q", strings.Join(got, ","), names)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasdLoadFrameOfFilesf(varName1 string, n int, varName2 string) error {
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	c, err := LoadCanvas(FramePath(f, n))
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) save_gifanimaFilesf(varName1, varName2 string) error {
+	a, ok := tt.Animations[varName1]
+	if !ok {
+		return fmt.Errorf("animation %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	return a.SaveGIF(f)
+}
+
+func (tt *tupletest) save_gifFails(varName1, varName2 string) error {
+	if err := tt.save_gifanimaFilesf(varName1, varName2); err == nil {
+		return fmt.Errorf("saving the GIF should fail")
+	}
+	return nil
+}
+
+func (tt *tupletest) gifgLoad_giffilesf(varName1, varName2 string) error {
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	in, err := os.Open(f)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	g, err := gif.DecodeAll(in)
+	if err != nil {
+		return err
+	}
+	tt.GIFs[varName1] = g
+	return nil
+}
+
+func (tt *tupletest) gifgHasFrames(varName1 string, n int) error {
+	g, ok := tt.GIFs[varName1]
+	if !ok {
+		return fmt.Errorf("GIF %s not available", varName1)
+	}
+	if len(g.Image) != n {
+		return fmt.Errorf("GIF has %d frames, not %d", len(g.Image), n)
+	}
+	return nil
+}
+
+func (tt *tupletest) gifgSetting(varName1, setting string, value int) error {
+	g, ok := tt.GIFs[varName1]
+	if !ok {
+		return fmt.Errorf("GIF %s not available", varName1)
+	}
+	if setting == "loop_count" {
+		if g.LoopCount != value {
+			return fmt.Errorf("loop count is %d, not %d", g.LoopCount, value)
+		}
+		return nil
+	}
+	for i, d := range g.Delay {
+		if d != value {
+			return fmt.Errorf("frame %d has a delay of %d, not %d", i+1, d, value)
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) gifgHasAPaletteOf(varName1 string, n int) error {
+	g, ok := tt.GIFs[varName1]
+	if !ok {
+		return fmt.Errorf("GIF %s not available", varName1)
+	}
+	for i, img := range g.Image {
+		if len(img.Palette) != n {
+			return fmt.Errorf("frame %d has %d colours, not %d", i+1, len(img.Palette), n)
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) frameOfGifgUsesColors(n int, varName1 string, colors int) error {
+	g, ok := tt.GIFs[varName1]
+	if !ok {
+		return fmt.Errorf("GIF %s not available", varName1)
+	}
+	if n < 1 || n > len(g.Image) {
+		return fmt.Errorf("GIF has no frame %d", n)
+	}
+	used := map[uint8]bool{}
+	for _, i := range g.Image[n-1].Pix {
+		used[i] = true
+	}
+	if len(used) != colors {
+		return fmt.Errorf("frame %d uses %d colours, not %d", n, len(used), colors)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasdFrameOfGifg(varName1 string, n int, varName2 string) error {
+	g, ok := tt.GIFs[varName2]
+	if !ok {
+		return fmt.Errorf("GIF %s not available", varName2)
+	}
+	if n < 1 || n > len(g.Image) {
+		return fmt.Errorf("GIF has no frame %d", n)
+	}
+	tt.Canvases[varName1] = NewCanvasFromImage(g.Image[n-1])
+	return nil
+}
+
+func (tt *tupletest) theMeanOfCanvasc(varName1 string, within, r, g, b float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	mean := NewColor(0, 0, 0)
+	for _, col := range c.Pixels {
+		mean = mean.Add(col)
+	}
+	mean = mean.MultiplyScalar(1 / float64(len(c.Pixels)))
+	if math.Abs(mean.Red-r) > within || math.Abs(mean.Green-g) > within || math.Abs(mean.Blue-b) > within {
+		return fmt.Errorf("mean of %s is %v", varName1, mean)
+	}
+	return nil
+}
+
+func (tt *tupletest) denoiserdDenoiser(varName1 string) error {
+	tt.Denoisers[varName1] = NewDenoiser()
+	return nil
+}
+
+func (tt *tupletest) denoiserdSetting(varName1, setting string, value float64) error {
+	d, ok := tt.Denoisers[varName1]
+	if !ok {
+		return fmt.Errorf("denoiser %s not available", varName1)
+	}
+	switch setting {
+	case "radius":
+		d.Radius = int(value)
+	case "spatial_sigma":
+		d.SpatialSigma = value
+	case "color_sigma":
+		d.ColorSigma = value
+	case "normal_sigma":
+		d.NormalSigma = value
+	case "albedo_sigma":
+		d.AlbedoSigma = value
+	case "depth_sigma":
+		d.DepthSigma = value
+	}
+	tt.Denoisers[varName1] = d
+	return nil
+}
+
+func (tt *tupletest) buffersbBuffers(varName1 string) error {
+	tt.AOVBuffers[varName1] = AOVBuffers{}
+	return nil
+}
+
+func (tt *tupletest) theBufferOfBuffersbIs(name, varName1, varName2 string) error {
+	b, ok := tt.AOVBuffers[varName1]
+	if !ok {
+		return fmt.Errorf("buffers %s not available", varName1)
+	}
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	b[name] = c
+	return nil
+}
+
+func (tt *tupletest) canvascASplitCanvas(varName1 string, w, h int, r1, g1, b1 float64, split int, r2, g2, b2 float64) error {
+	c := NewCanvas(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < split {
+				c.WritePixel(x, y, NewColor(r1, g1, b1))
+			} else {
+				c.WritePixel(x, y, NewColor(r2, g2, b2))
+			}
+		}
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) canvascCanvasaPlusCanvasb(varName1, varName2, varName3 string) error {
+	a, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	b, ok := tt.Canvases[varName3]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName3)
+	}
+	if a.Width != b.Width || a.Height != b.Height {
+		return fmt.Errorf("canvases are %dx%d and %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	c := NewCanvas(a.Width, a.Height)
+	for i := range c.Pixels {
+		c.Pixels[i] = a.Pixels[i].Add(b.Pixels[i])
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) denoise(varName1, varName2, varName3 string) (Canvas, error) {
+	d, ok := tt.Denoisers[varName1]
+	if !ok {
+		return Canvas{}, fmt.Errorf("denoiser %s not available", varName1)
+	}
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return Canvas{}, fmt.Errorf("canvas %s not available", varName2)
+	}
+	b, ok := tt.AOVBuffers[varName3]
+	if !ok {
+		return Canvas{}, fmt.Errorf("buffers %s not available", varName3)
+	}
+	return d.Denoise(c, b)
+}
+
+func (tt *tupletest) canvasoDenoise(varName1, varName2, varName3, varName4 string) error {
+	c, err := tt.denoise(varName2, varName3, varName4)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) denoiseFails(varName1, varName2, varName3 string) error {
+	if _, err := tt.denoise(varName1, varName2, varName3); err == nil {
+		return fmt.Errorf("denoising %s should fail", varName2)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvasoRender_denoised(varName1, varName2, varName3, varName4 string) error {
+	c, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("world %s not available", varName3)
+	}
+	d, ok := tt.Denoisers[varName4]
+	if !ok {
+		return fmt.Errorf("denoiser %s not available", varName4)
+	}
+	image, err := c.RenderDenoised(w, d)
+	if err != nil {
+		return err
+	}
+	tt.Canvases[varName1] = image
+	return nil
+}
+
+func (tt *tupletest) pixel_atIsWithin(varName1 string, x, y int, within, r, g, b float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	col := c.PixelAt(x, y)
+	if math.Abs(col.Red-r) > within || math.Abs(col.Green-g) > within || math.Abs(col.Blue-b) > within {
+		return fmt.Errorf("pixel %d, %d is %v", x, y, col)
+	}
+	return nil
+}
+
+func (tt *tupletest) pixel_atRedCompare(varName1 string, x, y int, op string, value float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	red := c.PixelAt(x, y).Red
+	if op == "<" && red >= value || op == ">" && red <= value {
+		return fmt.Errorf("pixel %d, %d has red %v, which is not %s %v", x, y, red, op, value)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvascHasAlpha(varName1, no string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	if c.HasAlpha() != (no == "") {
+		return fmt.Errorf("canvas %s has alpha: %v", varName1, c.HasAlpha())
+	}
+	return nil
+}
+
+func (tt *tupletest) add_alphacanvasc(varName1 string) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	c.AddAlpha()
+	tt.Canvases[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) write_alphacanvasc(varName1 string, x, y int, a float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	c.WriteAlpha(x, y, a)
+	return nil
+}
+
+func (tt *tupletest) alpha_atcanvasc(varName1 string, x, y int, a float64) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	if !epsilonEquals(c.AlphaAt(x, y), a) {
+		return fmt.Errorf("alpha at %d, %d is %v, not %v", x, y, c.AlphaAt(x, y), a)
+	}
+	return nil
+}
+
+func (tt *tupletest) cameracAlpha(varName1, on string) error {
+	c, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("camera %s not available", varName1)
+	}
+	c.Alpha = on == "true"
+	tt.Cameras[varName1] = c
+	return nil
+}
+
+func (tt *tupletest) coverage_atworldwRayr(varName1, varName2 string, expected float64) error {
+	w, ok := tt.Worlds[varName1]
+	if !ok {
+		return fmt.Errorf("world %s not available", varName1)
+	}
+	r, ok := tt.Rays[varName2]
+	if !ok {
+		return fmt.Errorf("ray %s not available", varName2)
+	}
+	if got := w.CoverageAt(r, maxReflects); !epsilonEquals(got, expected) {
+		return fmt.Errorf("coverage is %v, not %v", got, expected)
+	}
+	return nil
+}
+
+func (tt *tupletest) saveWithAlpha(varName1, varName2, mode string, d DisplayTransform) error {
+	c, ok := tt.Canvases[varName1]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName1)
+	}
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	opts := NewEncodeOptions()
+	opts.AlphaMode = mode
+	opts.Display = d
+	return c.SaveWith(f, opts)
+}
+
+func (tt *tupletest) savecanvascFilesfWithAlpha(varName1, varName2, mode string) error {
+	return tt.saveWithAlpha(varName1, varName2, mode, NewDisplayTransform())
+}
+
+func (tt *tupletest) savecanvascFilesfWithAlphaAndDisplayd(varName1, varName2, mode, varName3 string) error {
+	d, ok := tt.Displays[varName3]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName3)
+	}
+	return tt.saveWithAlpha(varName1, varName2, mode, d)
+}
+
+func (tt *tupletest) thePNGFilesfHasNRGBA(varName1 string, r, g, b, a, x, y int) error {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName1)
+	}
+	in, err := os.Open(f)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	img, err := png.Decode(in)
+	if err != nil {
+		return err
+	}
+	got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+	if want := (color.NRGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: uint8(a)}); got != want {
+		return fmt.Errorf("pixel %d, %d is %v, not %v", x, y, got, want)
+	}
+	return nil
+}
+
+func (tt *tupletest) adaptiveSamplesMatch(varName1 string, px1, py1 int64, x1, y1 float64, varName2 string, px2, py2 int64, x2, y2 float64) error {
+	c1, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	c2, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	a := c1.adaptiveCameraSample(px1, py1, x1, y1)
+	b := c2.adaptiveCameraSample(px2, py2, x2, y2)
+	if a.LensX != b.LensX || a.LensY != b.LensY || a.Time != b.Time {
+		return fmt.Errorf("%v and %v are not the same ray", a, b)
+	}
+	return nil
+}
+
+func (tt *tupletest) adaptiveSampleIsOffTheLensCentre(varName1 string, px, py int64, x, y float64) error {
+	c, ok := tt.Cameras[varName1]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName1)
+	}
+	if s := c.adaptiveCameraSample(px, py, x, y); s.LensX == 0 && s.LensY == 0 {
+		return fmt.Errorf("%v is at the centre of the lens", s)
+	}
+	return nil
+}
+
+func (tt *tupletest) boundsHoldsPointAllThroughTheShutter(varName1, x, y, z, varName2 string) error {
+	b, ok := tt.Bounds[varName1]
+	if !ok {
+		return fmt.Errorf("Bounds %s not available", varName1)
+	}
+	s, ok := tt.Shapes[varName2]
+	if !ok {
+		return fmt.Errorf("Shape %s not available", varName2)
+	}
+	local := NewPoint(StringToFloat(x), StringToFloat(y), StringToFloat(z))
+	for i := 0; i <= 1000; i++ {
+		time := float64(i) / 1000
+		m := TransformAt(s, time)
+		p := m.MultiplyTuple(local)
+		if p.X < b.Minimum.X || p.Y < b.Minimum.Y || p.Z < b.Minimum.Z ||
+			p.X > b.Maximum.X || p.Y > b.Maximum.Y || p.Z > b.Maximum.Z {
+			return fmt.Errorf("at time %v the point is at %v, outside %v to %v", time, p, b.Minimum, b.Maximum)
+		}
+	}
+	return nil
+}
+
+func (tt *tupletest) coordinatorsCoordinatorOnLoopbackWithATileTimeout(varName1, varName2, varName3 string, ms int) error {
+	a, ok := tt.Cameras[varName2]
+	if !ok {
+		return fmt.Errorf("Camera %s not available", varName2)
+	}
+	w, ok := tt.Worlds[varName3]
+	if !ok {
+		return fmt.Errorf("World %s not available", varName3)
+	}
+	co, err := NewCoordinator(w, a)
+	if err != nil {
+		return err
+	}
+	co.TileTimeout = time.Duration(ms) * time.Millisecond
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	served := &servedRender{Addr: l.Addr().String(), Done: make(chan struct{})}
+	go func() {
+		defer close(served.Done)
+		served.Image, served.Err = co.Serve(context.Background(), l)
+	}()
+	tt.Coordinators[varName1] = served
+	return nil
+}
+
+func (tt *tupletest) aWorkerTakesATileFromCoordinatorsAndHangs(varName1 string) error {
+	served, ok := tt.Coordinators[varName1]
+	if !ok {
+		return fmt.Errorf("Coordinator %s not available", varName1)
+	}
+	conn, err := net.Dial("tcp", served.Addr)
+	if err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(conn)
+	if err := dec.Decode(&SceneDescription{}); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := dec.Decode(&tileJob{}); err != nil {
+		conn.Close()
+		return err
+	}
+	// Hold the connection open without answering until the render is over
+	go func() {
+		<-served.Done
+		conn.Close()
+	}()
+	return nil
+}
+
+func (tt *tupletest) savingTheBufferOfBuffersbToFilesfFails(name, varName1, varName2 string) error {
+	if err := tt.saveTheBufferOfBuffersbToFilesf(name, varName1, varName2); err == nil {
+		return fmt.Errorf("saving the %s buffer of %s succeeded", name, varName1)
+	}
+	return nil
+}
+
+func (tt *tupletest) canvas_from_imageFailsHavingAllocatedUnder(format, varName1 string, mb int) error {
+	p, ok := tt.PPMs[varName1]
+	if !ok {
+		return fmt.Errorf("ppm %s not available", varName1)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var err error
+	if format == "ppm" {
+		_, err = ReadPPM(strings.NewReader(p))
+	} else {
+		_, err = readFloatImage(format, p)
+	}
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		return fmt.Errorf("expected reading the %s to fail", format)
+	}
+	if used := (after.TotalAlloc - before.TotalAlloc) >> 20; used >= uint64(mb) {
+		return fmt.Errorf("reading the %s allocated %d MB before failing with %q", format, used, err)
+	}
+	return nil
+}
+
+func (tt *tupletest) ppmpEncodecanvascWithDisplayd(varName1, varName2, format, varName3 string) error {
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	d, ok := tt.Displays[varName3]
+	if !ok {
+		return fmt.Errorf("display transform %s not available", varName3)
+	}
+	opts := NewEncodeOptions()
+	opts.Display = d
+	out := strings.Builder{}
+	if err := c.Encode(&out, format, opts); err != nil {
+		return err
+	}
+	tt.PPMs[varName1] = out.String()
+	return nil
+}
+
+func (tt *tupletest) ppmpTheContentsOfFilesf(varName1, varName2 string) error {
+	f, ok := tt.Files[varName2]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName2)
+	}
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return err
+	}
+	tt.PPMs[varName1] = string(data)
+	return nil
+}
+
+func (tt *tupletest) thePNGFilesfStoresCanvascAsItIs(varName1, varName2 string) error {
+	f, ok := tt.Files[varName1]
+	if !ok {
+		return fmt.Errorf("File %s not available", varName1)
+	}
+	c, ok := tt.Canvases[varName2]
+	if !ok {
+		return fmt.Errorf("canvas %s not available", varName2)
+	}
+	in, err := os.Open(f)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	img, err := png.Decode(in)
+	if err != nil {
+		return err
+	}
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			col := c.PixelAt(x, y)
+			want := color.NRGBA{
+				R: uint8(colorToDepth(col.Red, 255)),
+				G: uint8(colorToDepth(col.Green, 255)),
+				B: uint8(colorToDepth(col.Blue, 255)),
+				A: 255,
+			}
+			if got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA); got != want {
+				return fmt.Errorf("pixel %d, %d is %v, not %v", x, y, got, want)
+			}
+		}
+	}
+	return nil
+}
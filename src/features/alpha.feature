@@ -0,0 +1,290 @@
+Feature: Alpha
+    Per-pixel coverage tracked through the camera samples and written to RGBA PNGs
+
+    Scenario: A canvas starts without alpha and is opaque
+        Given canvas.c ← canvas(2, 2)
+        Then canvas.c has no alpha
+        And alpha_at(canvas.c, 1, 1) = 1
+        And alpha_at(canvas.c, 2, 1) = 0
+
+    Scenario: Writing alpha
+        Given canvas.c ← canvas(2, 2)
+        When add_alpha(canvas.c)
+        And write_alpha(canvas.c, 1, 0, 0.25)
+        Then canvas.c has alpha
+        And alpha_at(canvas.c, 0, 0) = 1
+        And alpha_at(canvas.c, 1, 0) = 0.25
+
+    Scenario: A region keeps the alpha and is transparent past the edge
+        Given canvas.c ← canvas(2, 2)
+        And add_alpha(canvas.c)
+        And write_alpha(canvas.c, 0, 0, 0.5)
+        When canvas.r ← region(canvas.c, -1, 0, 2, 1)
+        Then alpha_at(canvas.r, 0, 0) = 0
+        And alpha_at(canvas.r, 1, 0) = 0.5
+
+    Scenario: A ray that hits an opaque surface covers the pixel
+        Given world.w ← default_world()
+        And ray.r ← ray(point(0, 0, -5), vector(0, 0, 1))
+        Then coverage_at(world.w, ray.r) = 1
+
+    Scenario: A ray that misses leaves the background showing
+        Given world.w ← default_world()
+        And ray.r ← ray(point(0, 0, -5), vector(0, 1, 0))
+        Then coverage_at(world.w, ray.r) = 0
+
+    Scenario: A transparent surface only partly covers what is behind it
+        Given world.w ← world()
+        And world.w.light ← point_light(point(-10, 10, -10), color(1, 1, 1))
+        And shapes.ball ← sphere() with:
+            | material.transparency     | 0.5 |
+            | material.refractive_index | 1.5 |
+        And shapes.ball is added to world.w
+        And ray.r ← ray(point(0, 0, -5), vector(0, 0, 1))
+        Then coverage_at(world.w, ray.r) = 0.75
+
+    Scenario: A transparent surface over an opaque one covers the pixel
+        Given world.w ← default_world()
+        And shapes.outer ← the first object in world.w
+        And shapes.outer has:
+            | material.transparency     | 0.5 |
+            | material.refractive_index | 1.5 |
+        And ray.r ← ray(point(0, 0, -5), vector(0, 0, 1))
+        Then coverage_at(world.w, ray.r) = 1
+
+    Scenario: A render has alpha only when the camera asks for it
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When canvas.image ← render(camera.c, world.w)
+        Then canvas.image has no alpha
+
+    Scenario: Rendering tracks the coverage of every sample
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.samples ← 4
+        And camera.c.alpha ← true
+        When canvas.image ← render(camera.c, world.w)
+        Then canvas.image has alpha
+        And alpha_at(canvas.image, 5, 5) = 1
+        And alpha_at(canvas.image, 5, 4) = 0.5
+        And alpha_at(canvas.image, 4, 4) = 0.1875
+        And alpha_at(canvas.image, 0, 0) = 0
+        And pixel_at(canvas.image, 0, 0) = color(0, 0, 0)
+
+    Scenario: Rendering a transparent object gives partial alpha
+        Given world.w ← world()
+        And world.w.light ← point_light(point(-10, 10, -10), color(1, 1, 1))
+        And shapes.ball ← sphere() with:
+            | material.transparency     | 0.5 |
+            | material.refractive_index | 1.5 |
+        And shapes.ball is added to world.w
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.alpha ← true
+        When canvas.image ← render(camera.c, world.w)
+        Then alpha_at(canvas.image, 5, 5) = 0.75
+
+    Scenario: Adaptive sampling takes alpha from its own samples
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.sample_mode ← "adaptive"
+        And camera.c.alpha ← true
+        When canvas.image ← render(camera.c, world.w)
+        Then alpha_at(canvas.image, 5, 5) = 1
+        # Four corners alone could only give quarters
+        And alpha_at(canvas.image, 4, 4) = 0.203125
+        And alpha_at(canvas.image, 0, 0) = 0
+
+    Scenario Outline: Every way of rendering keeps the alpha
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.samples ← 4
+        And camera.c.tile_size ← 4
+        And camera.c.alpha ← true
+        And files.f ← a temporary checkpoint
+        When canvas.plain ← render(camera.c, world.w)
+        And canvas.image ← <render>
+        Then canvas.image has alpha
+        And canvas.image = canvas.plain
+
+        Examples:
+            | render                                              |
+            | render_with_progress(camera.c, world.w)             |
+            | render_with_checkpoint(camera.c, world.w, files.f)  |
+            | render(camera.c, world.w) with buffers.b "depth"    |
+            | render(camera.c, world.w) after a scene round trip  |
+
+    Scenario: A resumed checkpoint keeps the alpha
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        And camera.c.alpha ← true
+        And files.f ← a temporary checkpoint
+        When canvas.first ← render_with_checkpoint(camera.c, world.w, files.f)
+        And canvas.image ← render_with_checkpoint(camera.c, world.w, files.f)
+        Then load_checkpoint(files.f, camera.c, world.w) has 9 tiles
+        And canvas.image = canvas.first
+        And alpha_at(canvas.image, 0, 0) = 0
+
+    Scenario: Loopback workers send the alpha back
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        And camera.c.alpha ← true
+        When coordinators.co ← coordinator(camera.c, world.w) on loopback
+        And 2 workers join coordinators.co
+        And canvas.image ← the render from coordinators.co
+        And canvas.plain ← render(camera.c, world.w)
+        Then canvas.image has alpha
+        And canvas.image = canvas.plain
+
+    Scenario: Denoising filters the alpha with the colour
+        Given denoiser.d ← denoiser()
+        And buffers.b ← buffers()
+        And canvas.c ← canvas(2, 1)
+        And add_alpha(canvas.c)
+        And write_alpha(canvas.c, 1, 0, 0)
+        When canvas.o ← denoise(denoiser.d, canvas.c, buffers.b)
+        Then canvas.o has alpha
+        And alpha_at(canvas.o, 0, 0) = 0.53121
+        And alpha_at(canvas.o, 1, 0) = 0.46879
+
+    Scenario Outline: Stereo pairs laid out together keep their alpha
+        Given canvas.l ← canvas(2, 1)
+        And add_alpha(canvas.l)
+        And write_alpha(canvas.l, 0, 0, 0.25)
+        And canvas.r ← canvas(2, 1)
+        When canvas.s ← compose_stereo(canvas.l, canvas.r, "<layout>")
+        Then canvas.s has alpha
+        And alpha_at(canvas.s, 0, 0) = 0.25
+        And alpha_at(canvas.s, <x>, <y>) = 1
+
+        Examples:
+            | layout       | x | y |
+            | side-by-side | 2 | 0 |
+            | over-under   | 0 | 1 |
+
+    Scenario: An anaglyph cannot keep alpha
+        Given canvas.l ← canvas(2, 1)
+        And add_alpha(canvas.l)
+        And canvas.r ← canvas(2, 1)
+        Then compose_stereo(canvas.l, canvas.r, "anaglyph") fails
+
+    Scenario: A cube map cross is transparent around the faces
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.alpha ← true
+        When the cube map of camera.c in world.w is rendered 11 pixels across
+        And canvas.cross ← cube_map_cross()
+        Then canvas.cross has alpha
+        And alpha_at(canvas.cross, 0, 0) = 0
+        And alpha_at(canvas.cross, 16, 16) = 1
+        And alpha_at(canvas.cross, 38, 16) = 0
+
+    Scenario Outline: A PNG keeps alpha straight, premultiplied or not at all
+        Given canvas.c ← canvas(2, 1)
+        And colors.c ← color(0.25, 0.25, 0)
+        And write_pixel(canvas.c, 0, 0, colors.c)
+        And add_alpha(canvas.c)
+        And write_alpha(canvas.c, 0, 0, 0.5)
+        And write_alpha(canvas.c, 1, 0, 0)
+        And files.f ← a temporary file "out.png"
+        When save(canvas.c, files.f) with <mode> alpha
+        Then the PNG files.f has nrgba(<first>) at 0, 0
+        And the PNG files.f has nrgba(<second>) at 1, 0
+
+        Examples:
+            | mode          | first             | second        |
+            | straight      | 188, 188, 0, 128  | 0, 0, 0, 0    |
+            | premultiplied | 94, 94, 0, 128    | 0, 0, 0, 0    |
+            | none          | 137, 137, 0, 255  | 0, 0, 0, 255  |
+
+    Scenario Outline: The display transform sees colours before they are premultiplied
+        Given canvas.c ← canvas(1, 1)
+        And colors.c ← color(0.25, 0.25, 0)
+        And write_pixel(canvas.c, 0, 0, colors.c)
+        And add_alpha(canvas.c)
+        And write_alpha(canvas.c, 0, 0, 0.5)
+        And display.d ← display_transform()
+        And display.d.exposure ← -1
+        And files.f ← a temporary file "out.png"
+        When save(canvas.c, files.f) with <mode> alpha and display.d
+        Then the PNG files.f has nrgba(<pixel>) at 0, 0
+
+        Examples:
+            | mode          | pixel              |
+            | straight      | 137, 137, 0, 128   |
+            | premultiplied | 68, 68, 0, 128     |
+
+    Scenario Outline: Effects spread alpha with the light
+        Given canvas.c ← canvas(3, 1)
+        And colors.c ← <color>
+        And write_pixel(canvas.c, 1, 0, colors.c)
+        And add_alpha(canvas.c)
+        And write_alpha(canvas.c, 0, 0, 0)
+        And write_alpha(canvas.c, 2, 0, 0)
+        And post.p ← post_process("<effect>")
+        And files.f ← a temporary file "out.png"
+        When save(canvas.c, files.f) with post.p
+        # Light spilling past the edge keeps its colour rather than being
+        # lost, and the edge is not darkened by the background
+        Then the PNG files.f has nrgba(<spill>) at 0, 0
+        And the PNG files.f has nrgba(<edge>) at 1, 0
+
+        Examples:
+            | effect                     | color                | spill             | edge               |
+            | convolve:3,1,0.25,0.5,0.25 | color(0.8, 0.8, 0.8) | 231, 231, 231, 64 | 231, 231, 231, 128 |
+            | blur:1                     | color(0.8, 0.8, 0.8) | 231, 231, 231, 62 | 231, 231, 231, 102 |
+            | chromatic:0.25             | color(0.8, 0.8, 0.8) | 231, 0, 0, 51     | 231, 231, 231, 255 |
+            | bloom:1,1,0.5              | color(3, 3, 3)       | 255, 255, 255, 62 | 255, 255, 255, 255 |
+
+    Scenario: Loading a PNG with alpha gives a premultiplied canvas
+        Given canvas.c ← canvas(1, 1)
+        And colors.c ← color(0.25, 0.25, 0)
+        And write_pixel(canvas.c, 0, 0, colors.c)
+        And add_alpha(canvas.c)
+        And write_alpha(canvas.c, 0, 0, 0.5)
+        And files.f ← a temporary file "out.png"
+        When save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then canvas.d has alpha
+        And alpha_at(canvas.d, 0, 0) = 0.50196
+        And pixel_at(canvas.d, 0, 0) is within 0.005 of color(0.25, 0.25, 0)
+
+    Scenario: Loading an opaque PNG gives a canvas without alpha
+        Given canvas.c ← canvas(1, 1)
+        And files.f ← a temporary file "out.png"
+        When save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then canvas.d has no alpha
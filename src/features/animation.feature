@@ -0,0 +1,140 @@
+Feature: Animation
+    Sequences of canvases saved as numbered frames or an animated GIF
+
+    Background:
+        Given canvas.a ← a 4x4 checkerboard of color(1, 0, 0) and color(0, 0, 1)
+        And canvas.b ← a 4x4 checkerboard of color(0, 1, 0) and color(1, 1, 1)
+        And anim.a ← animation()
+
+    Scenario: Frames are saved as numbered files
+        Given files.f ← a temporary file "frame_%04d.png"
+        And add_frame(anim.a, canvas.a)
+        And add_frame(anim.a, canvas.b)
+        And add_frame(anim.a, canvas.a)
+        When save_frames(anim.a, files.f)
+        And canvas.d ← load(frame 2 of files.f)
+        Then the directory of files.f holds "frame_0001.png,frame_0002.png,frame_0003.png"
+        And canvas.d matches canvas.b to within 0.0001
+
+    Scenario: A frame pattern has to number the frames
+        Given files.f ← a temporary file "frame.png"
+        And add_frame(anim.a, canvas.a)
+        Then save_frames(anim.a, files.f) fails
+
+    Scenario: Frames go through the display transform
+        Given files.f ← a temporary file "frame_%02d.png"
+        And canvas.c ← a 2x2 checkerboard of color(0.251445, 0.10793, 0) and color(0, 0.251445, 0.5)
+        And canvas.bright ← a 2x2 checkerboard of color(0.50289, 0.21586, 0) and color(0, 0.50289, 1)
+        And display.d ← display_transform()
+        And display.d.exposure ← 1
+        And anim.a.encode.display ← display.d
+        And add_frame(anim.a, canvas.c)
+        When save_frames(anim.a, files.f)
+        And canvas.d ← load(frame 1 of files.f)
+        Then the directory of files.f holds "frame_01.png"
+        And canvas.d matches canvas.bright to within 0.0001
+
+    Scenario: A GIF shows each frame for 4/100 of a second and loops forever by default
+        Given files.f ← a temporary file "out.gif"
+        And add_frame(anim.a, canvas.a)
+        And add_frame(anim.a, canvas.b)
+        When save_gif(anim.a, files.f)
+        And gif.g ← load_gif(files.f)
+        Then gif.g has 2 frames
+        And gif.g.delay = 4
+        And gif.g.loop_count = 0
+
+    Scenario Outline: The frame delay and loop count can be set
+        Given files.f ← a temporary file "out.gif"
+        And anim.a.delay ← <delay>
+        And anim.a.loop_count ← <loops>
+        And add_frame(anim.a, canvas.a)
+        And add_frame(anim.a, canvas.b)
+        And add_frame(anim.a, canvas.a)
+        When save_gif(anim.a, files.f)
+        And gif.g ← load_gif(files.f)
+        Then gif.g has 3 frames
+        And gif.g.delay = <delay>
+        And gif.g.loop_count = <loops>
+
+        Examples:
+            | delay | loops |
+            | 10    | 3     |
+            | 50    | -1    |
+
+    Scenario: A GIF with few colours keeps them exactly
+        Given files.f ← a temporary file "out.gif"
+        And add_frame(anim.a, canvas.a)
+        And add_frame(anim.a, canvas.b)
+        When save_gif(anim.a, files.f)
+        And gif.g ← load_gif(files.f)
+        And canvas.d ← frame 1 of gif.g
+        And canvas.e ← frame 2 of gif.g
+        Then gif.g has a palette of 4 colors
+        And canvas.d = canvas.a
+        And canvas.e = canvas.b
+
+    Scenario: Median cut shares a smaller palette between the frames
+        Given files.f ← a temporary file "out.gif"
+        And anim.a.palette_size ← 2
+        And anim.a.dither ← false
+        And add_frame(anim.a, canvas.a)
+        And add_frame(anim.a, canvas.b)
+        When save_gif(anim.a, files.f)
+        And gif.g ← load_gif(files.f)
+        Then gif.g has a palette of 2 colors
+        And frame 1 of gif.g uses 2 colors
+        And frame 2 of gif.g uses 2 colors
+
+    Scenario: Without dithering a flat colour takes its nearest palette entry
+        Given files.f ← a temporary file "out.gif"
+        And canvas.c ← a 8x8 checkerboard of color(0.5, 0.5, 0.5) and color(0.5, 0.5, 0.5)
+        And anim.a.palette ← "000000,ffffff"
+        And anim.a.dither ← false
+        And add_frame(anim.a, canvas.c)
+        When save_gif(anim.a, files.f)
+        And gif.g ← load_gif(files.f)
+        Then frame 1 of gif.g uses 1 colors
+
+    Scenario: Dithering mixes palette entries to keep the average colour
+        Given files.f ← a temporary file "out.gif"
+        And canvas.c ← a 8x8 checkerboard of color(0.5, 0.5, 0.5) and color(0.5, 0.5, 0.5)
+        And anim.a.palette ← "000000,ffffff"
+        And add_frame(anim.a, canvas.c)
+        When save_gif(anim.a, files.f)
+        And gif.g ← load_gif(files.f)
+        And canvas.d ← frame 1 of gif.g
+        Then frame 1 of gif.g uses 2 colors
+        # Dithering mixes the sRGB encoded colours the GIF stores, and 0.5 is
+        # 0.73536 encoded
+        And the mean of canvas.d is within 0.05 of color(0.73536, 0.73536, 0.73536)
+
+    Scenario: A GIF needs frames of one size
+        Given files.f ← a temporary file "out.gif"
+        And canvas.c ← canvas(2, 2)
+        And add_frame(anim.a, canvas.a)
+        And add_frame(anim.a, canvas.c)
+        Then save_gif(anim.a, files.f) fails
+
+    Scenario: A GIF needs at least one frame
+        Given files.f ← a temporary file "out.gif"
+        Then save_gif(anim.a, files.f) fails
+
+    Scenario: A GIF palette holds at most 256 colours
+        Given files.f ← a temporary file "out.gif"
+        And anim.a.palette_size ← 300
+        And add_frame(anim.a, canvas.a)
+        Then save_gif(anim.a, files.f) fails
+
+    Scenario: A turntable turns the world in front of the camera
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When anim.t ← render_turntable(camera.c, world.w, 4)
+        And canvas.first ← frame 1 of anim.t
+        And canvas.image ← render(camera.c, world.w)
+        Then anim.t has 4 frames
+        And canvas.first = canvas.image
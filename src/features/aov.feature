@@ -0,0 +1,155 @@
+Feature: Arbitrary output variables
+    Depth, normal, albedo, object ID and shadow buffers rendered alongside the color
+
+    Scenario: The AOVs of a ray that hits the default world
+        Given world.w ← default_world()
+        And sphere.s ← the first object in world.w
+        And ray.r ← ray(point(0, 0, -5), vector(0, 0, 1))
+        When aov.a ← aov_at(world.w, ray.r)
+        Then aov.a.hit is true
+        And aov.a.depth = 4
+        And aov.a.normal = vector(0, 0, -1)
+        And aov.a.albedo = color(0.8, 1.0, 0.6)
+        And aov.a.object_id is the id of sphere.s
+        And aov.a.shadowed is false
+
+    Scenario: The AOVs of a ray that misses
+        Given world.w ← default_world()
+        And ray.r ← ray(point(0, 0, -5), vector(0, 1, 0))
+        When aov.a ← aov_at(world.w, ray.r)
+        Then aov.a.hit is false
+        And aov.a.depth = 0
+        And aov.a.albedo = color(0, 0, 0)
+
+    Scenario: The shadow AOV of a point in shadow
+        Given world.w ← world()
+        And world.w.light ← point_light(point(0, 0, -10), color(1, 1, 1))
+        And sphere.s1 ← sphere()
+        And sphere.s1 is added to world.w
+        And sphere.s2 ← sphere() with:
+            | transform | translation(0, 0, 10) |
+        And sphere.s2 is added to world.w
+        And ray.r ← ray(point(0, 0, 5), vector(0, 0, 1))
+        When aov.a ← aov_at(world.w, ray.r)
+        Then aov.a.shadowed is true
+        And aov.a.depth = 4
+        And aov.a.object_id is the id of sphere.s2
+
+    Scenario: The albedo AOV takes the pattern color
+        Given world.w ← default_world()
+        And sphere.s ← the first object in world.w
+        And material.m ← material()
+        And material.m.pattern ← stripe_pattern(color(1, 1, 1), color(0, 0, 0))
+        And sphere.s.material ← material.m
+        And ray.r ← ray(point(-0.5, 0, -5), vector(0, 0, 1))
+        When aov.a ← aov_at(world.w, ray.r)
+        Then aov.a.albedo = color(0, 0, 0)
+
+    Scenario: Rendering AOV buffers alongside the color
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When canvas.image ← render(camera.c, world.w) with buffers.b "depth,normal,albedo,shadow"
+        And canvas.depth ← the "depth" buffer of buffers.b
+        And canvas.normal ← the "normal" buffer of buffers.b
+        And canvas.albedo ← the "albedo" buffer of buffers.b
+        And canvas.shadow ← the "shadow" buffer of buffers.b
+        Then pixel_at(canvas.image, 5, 5) = color(0.38066, 0.47583, 0.2855)
+        And pixel_at(canvas.depth, 5, 5) = color(4, 4, 4)
+        And pixel_at(canvas.depth, 0, 0) = color(0, 0, 0)
+        And pixel_at(canvas.normal, 5, 5) = color(0, 0, -1)
+        And pixel_at(canvas.albedo, 5, 5) = color(0.8, 1.0, 0.6)
+        And pixel_at(canvas.shadow, 5, 5) = color(0, 0, 0)
+        And buffers.b has no "object_id" buffer
+
+    Scenario: AOV buffers do not change the color render
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When canvas.plain ← render(camera.c, world.w)
+        And canvas.image ← render(camera.c, world.w) with buffers.b "object_id"
+        Then canvas.image = canvas.plain
+
+    Scenario: An unknown AOV is an error
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        Then render(camera.c, world.w) with buffers.b "depth,motion" fails
+
+    Scenario: AOV buffers are turned into images for viewing
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When canvas.image ← render(camera.c, world.w) with buffers.b "depth,normal"
+        And canvas.depth ← the "depth" image of buffers.b
+        And canvas.normal ← the "normal" image of buffers.b
+        Then pixel_at(canvas.depth, 5, 5) = color(1, 1, 1)
+        And pixel_at(canvas.depth, 0, 0) = color(0, 0, 0)
+        And pixel_at(canvas.normal, 5, 5) = color(0.5, 0.5, 0)
+
+    Scenario: AOV buffers are saved raw as PFM
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And files.f ← a temporary file "depth.pfm"
+        When canvas.image ← render(camera.c, world.w) with buffers.b "depth"
+        And canvas.depth ← the "depth" buffer of buffers.b
+        And save the "depth" buffer of buffers.b to files.f
+        And canvas.loaded ← load(files.f)
+        Then canvas.loaded matches canvas.depth to within 0.000001
+
+    Scenario Outline: Normals and object IDs cannot be saved as HDR
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And files.f ← a temporary file "<name>.hdr"
+        When canvas.image ← render(camera.c, world.w) with buffers.b "<name>"
+        Then saving the "<name>" buffer of buffers.b to files.f fails
+
+        Examples:
+            | name      |
+            | normal    |
+            | object_id |
+
+    Scenario Outline: AOV buffers are saved as their image in other formats
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And files.f ← a temporary file "<name>.png"
+        When canvas.image ← render(camera.c, world.w) with buffers.b "<name>"
+        And save the "<name>" buffer of buffers.b to files.f
+        Then the PNG files.f has <nrgba> at <x>, <y>
+
+        # Normals and depths are stored linearly for compositing; only
+        # albedo, a colour, is sRGB encoded
+        Examples:
+            | name      | x | y | nrgba                     |
+            | normal    | 5 | 5 | nrgba(128, 128, 0, 255)   |
+            | depth     | 5 | 4 | nrgba(51, 51, 51, 255)    |
+            | albedo    | 5 | 5 | nrgba(231, 255, 203, 255) |
+
+    Scenario: Object IDs are saved without the sRGB curve
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And files.f ← a temporary file "object_id.png"
+        When canvas.image ← render(camera.c, world.w) with buffers.b "object_id"
+        And canvas.ids ← the "object_id" image of buffers.b
+        And save the "object_id" buffer of buffers.b to files.f
+        Then the PNG files.f stores canvas.ids as it is
@@ -38,4 +38,371 @@ Feature: Camera
         And tuple.up ← vector(0, 1, 0)
         And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
         When canvas.image ← render(camera.c, world.w)
-        Then pixel_at(canvas.image, 5, 5) = color(0.38066, 0.47583, 0.2855)
\ No newline at end of file
+        Then pixel_at(canvas.image, 5, 5) = color(0.38066, 0.47583, 0.2855)
+    Scenario: Splitting the image into tiles
+        Given camera.c ← camera(11, 7, π/2)
+        And camera.c.tile_size ← 4
+        Then camera.c has 6 tiles
+    Scenario: Rendering on many workers matches a single worker
+        Given world.w ← default_world()
+        And camera.serial ← camera(11, 11, π/2)
+        And camera.parallel ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.serial.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.parallel.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.serial.workers ← 1
+        And camera.parallel.workers ← 4
+        And camera.parallel.tile_size ← 3
+        When canvas.a ← render(camera.serial, world.w)
+        And canvas.b ← render(camera.parallel, world.w)
+        Then canvas.a = canvas.b
+        And pixel_at(canvas.b, 5, 5) = color(0.38066, 0.47583, 0.2855)
+
+    Scenario: A single sample passes through the centre of the pixel
+        Given camera.c ← camera(201, 101, π/2)
+        Then camera.c has 1 samples for pixel 3, 4
+        And sample 0 of camera.c for pixel 3, 4 = (0.5, 0.5)
+    Scenario: Regular grid sampling
+        Given camera.c ← camera(201, 101, π/2)
+        And camera.c.samples ← 2
+        Then camera.c has 4 samples for pixel 3, 4
+        And sample 0 of camera.c for pixel 3, 4 = (0.25, 0.25)
+        And sample 1 of camera.c for pixel 3, 4 = (0.75, 0.25)
+        And sample 3 of camera.c for pixel 3, 4 = (0.75, 0.75)
+    Scenario: Jittered samples stay inside their strata
+        Given camera.c ← camera(201, 101, π/2)
+        And camera.c.samples ← 4
+        And camera.c.sample_mode ← "jitter"
+        Then camera.c has 16 samples for pixel 3, 4
+        And every sample of camera.c for pixel 3, 4 lies in its stratum
+    Scenario: Seeded sampling renders reproducibly
+        Given world.w ← default_world()
+        And camera.a ← camera(11, 11, π/2)
+        And camera.b ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.a.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.b.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And filters.f ← mitchell_filter(1, 0.33333, 0.33333)
+        And camera.a.samples ← 2
+        And camera.a.sample_mode ← "random"
+        And camera.a.seed ← 7
+        And camera.a.filter ← filters.f
+        And camera.b.samples ← 2
+        And camera.b.sample_mode ← "random"
+        And camera.b.seed ← 7
+        And camera.b.filter ← filters.f
+        And camera.b.workers ← 1
+        When canvas.a ← render(camera.a, world.w)
+        And canvas.b ← render(camera.b, world.w)
+        Then canvas.a = canvas.b
+    Scenario Outline: Filter weights
+        Given filters.f ← <filter>
+        Then weight(filters.f, <dx>, <dy>) = <weight>
+
+        Examples:
+            | filter                                 | dx  | dy  | weight  |
+            | box_filter()                           | 0.3 | 0.1 | 1       |
+            | box_filter()                           | 0.6 | 0   | 0       |
+            | tent_filter(1)                         | 0.5 | 0   | 0.5     |
+            | tent_filter(1)                         | 0.5 | 0.5 | 0.25    |
+            | gaussian_filter(1, 2)                  | 0   | 0   | 0.74765 |
+            | gaussian_filter(1, 2)                  | 1   | 0   | 0       |
+            | mitchell_filter(1, 0.33333, 0.33333)   | 0   | 0   | 0.79013 |
+            | mitchell_filter(1, 0.33333, 0.33333)   | 1   | 0   | 0       |
+
+    Scenario: Adaptive sampling fires no extra rays across a flat image
+        Given world.w ← default_world()
+        And camera.c ← camera(5, 5, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, -10)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.sample_mode ← "adaptive"
+        When canvas.image ← render(camera.c, world.w)
+        Then camera.c fired 36 camera rays and 0 extra rays
+        And pixel_at(canvas.image, 2, 2) = color(0, 0, 0)
+
+    Scenario: Adaptive sampling traces corners shared by tiles once
+        Given world.w ← default_world()
+        And camera.c ← camera(5, 5, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, -10)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 2
+        And camera.c.sample_mode ← "adaptive"
+        When canvas.image ← render(camera.c, world.w)
+        Then camera.c fired 36 camera rays and 0 extra rays
+
+    Scenario: Adaptive samples use the lens, the same way from either side of a corner
+        Given camera.c ← camera(5, 5, π/2)
+        And camera.c.aperture ← 0.5
+        And camera.c.sample_mode ← "adaptive"
+        Then adaptive_sample(camera.c, 1, 0, 0, 0.5) is off the lens centre
+        And adaptive_sample(camera.c, 1, 0, 0, 0.5) = adaptive_sample(camera.c, 0, 0, 1, 0.5)
+        And adaptive_sample(camera.c, 1, 1, 0, 0) = adaptive_sample(camera.c, 0, 0, 1, 1)
+
+    Scenario: Adaptive sampling subdivides pixels along edges
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        And camera.c.sample_mode ← "adaptive"
+        And camera.c.adaptive_threshold ← 0.2
+        And camera.c.adaptive_depth ← 2
+        When canvas.image ← render(camera.c, world.w)
+        Then camera.c fired some extra rays
+        And pixel_at(canvas.image, 0, 0) = color(0, 0, 0)
+
+    Scenario: A thin lens ray starts on the lens and passes through the focal plane
+        Given camera.c ← camera(201, 101, π/2)
+        And camera.c.aperture ← 0.5
+        And camera.c.focal_distance ← 10
+        When ray.r ← ray_for_sample(camera.c, 100, 50, 0.5, 0.5, lens 1, 0)
+        Then ray.r.origin = point(0.5, 0, 0)
+        And ray.r.direction = vector(-0.04994, 0, -0.99875)
+    Scenario: A zero aperture keeps the pinhole ray
+        Given camera.c ← camera(201, 101, π/2)
+        And camera.c.focal_distance ← 10
+        When ray.r ← ray_for_sample(camera.c, 0, 0, 0.5, 0.5, lens 1, 0)
+        Then ray.r.origin = point(0, 0, 0)
+        And ray.r.direction = vector(0.66519, 0.33259, -0.66851)
+    Scenario: Lens samples are spread over the unit disk
+        Given camera.c ← camera(201, 101, π/2)
+        And camera.c.aperture ← 0.2
+        And camera.c.samples ← 3
+        And camera.c.sample_mode ← "jitter"
+        Then camera.c has 9 samples for pixel 7, 9
+        And every lens sample of camera.c for pixel 7, 9 lies on the unit disk
+
+    Scenario: The pixel size of an orthographic camera
+        Given camera.c ← orthographic_camera(200, 100, 4, 2)
+        Then camera.c.pixel_size = 0.02
+    Scenario: An orthographic window is widened to fit the image
+        Given camera.c ← orthographic_camera(200, 100, 4, 4)
+        Then camera.c.pixel_size = 0.04
+    Scenario: Orthographic rays are parallel
+        Given camera.c ← orthographic_camera(201, 101, 4.02, 2.02)
+        When ray.r ← ray_for_pixel(camera.c, 100, 50)
+        And ray.s ← ray_for_pixel(camera.c, 0, 0)
+        Then ray.r.origin = point(0, 0, 0)
+        And ray.r.direction = vector(0, 0, -1)
+        And ray.s.origin = point(2, 1, 0)
+        And ray.s.direction = vector(0, 0, -1)
+    Scenario: Orthographic rays follow the camera transform
+        Given camera.c ← orthographic_camera(201, 101, 4.02, 2.02)
+        When camera.c.transform ← rotation_y(π/4) * translation(0, -2, 5)
+        And ray.r ← ray_for_pixel(camera.c, 100, 50)
+        Then ray.r.origin = point(0, 2, -5)
+        And ray.r.direction = vector(√2/2, 0, -√2/2)
+    Scenario: Rendering a world with an orthographic camera
+        Given world.w ← default_world()
+        And camera.c ← orthographic_camera(11, 11, 2.2, 2.2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When canvas.image ← render(camera.c, world.w)
+        Then pixel_at(canvas.image, 5, 5) = color(0.38066, 0.47583, 0.2855)
+        And pixel_at(canvas.image, 0, 0) = color(0, 0, 0)
+
+    Scenario Outline: Panoramic rays cover every direction
+        Given camera.c ← panoramic_camera(360, 180)
+        When ray.r ← ray_for_sample(camera.c, <x>, <y>, 0, 0, lens 0, 0)
+        Then ray.r.origin = point(0, 0, 0)
+        And ray.r.direction = <direction>
+
+        Examples:
+            | x   | y   | direction          |
+            | 180 | 90  | vector(0, 0, -1)   |
+            | 90  | 90  | vector(1, 0, 0)    |
+            | 270 | 90  | vector(-1, 0, 0)   |
+            | 0   | 90  | vector(0, 0, 1)    |
+            | 180 | 0   | vector(0, 1, 0)    |
+            | 180 | 45  | vector(0, √2/2, -√2/2) |
+    Scenario: Panoramic rays follow the camera transform
+        Given camera.c ← panoramic_camera(360, 180)
+        When camera.c.transform ← rotation_y(π/4) * translation(0, -2, 5)
+        And ray.r ← ray_for_sample(camera.c, 180, 90, 0, 0, lens 0, 0)
+        Then ray.r.origin = point(0, 2, -5)
+        And ray.r.direction = vector(√2/2, 0, -√2/2)
+    Scenario: Rendering a cube map
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When the cube map of camera.c in world.w is rendered 11 pixels across
+        And canvas.cross ← cube_map_cross()
+        Then pixel_at(canvas.front, 5, 5) = color(0.38066, 0.47583, 0.2855)
+        And pixel_at(canvas.back, 5, 5) = color(0, 0, 0)
+        And pixel_at(canvas.left, 5, 5) = color(0, 0, 0)
+        And canvas.cross.width = 44
+        And canvas.cross.height = 33
+        And pixel_at(canvas.cross, 16, 16) = color(0.38066, 0.47583, 0.2855)
+
+    Scenario Outline: Fisheye rays
+        Given camera.c ← fisheye_camera(200, 100, π, "<mapping>")
+        When ray.r ← ray_for_sample(camera.c, <x>, 50, 0, 0, lens 0, 0)
+        Then ray.r.origin = point(0, 0, 0)
+        And ray.r.direction = <direction>
+
+        Examples:
+            | mapping     | x   | direction                 |
+            | equidistant | 100 | vector(0, 0, -1)          |
+            | equidistant | 50  | vector(1, 0, 0)           |
+            | equidistant | 75  | vector(√2/2, 0, -√2/2)    |
+            | equisolid   | 100 | vector(0, 0, -1)          |
+            | equisolid   | 75  | vector(0.66144, 0, -0.75) |
+            | equisolid   | 150 | vector(-1, 0, 0)          |
+    Scenario: A fisheye can see behind the camera
+        Given camera.c ← fisheye_camera(100, 100, 4.712389, "equidistant")
+        When ray.r ← ray_for_sample(camera.c, 50, 0, 0, 0, lens 0, 0)
+        Then ray.r.direction = vector(0, √2/2, √2/2)
+    Scenario: Pixels outside the fisheye image circle have no ray
+        Given camera.c ← fisheye_camera(200, 100, π, "equidistant")
+        Then camera.c has no ray for pixel 0, 0
+        And camera.c has no ray for pixel 160, 50
+    Scenario: Brown–Conrady radial distortion
+        Given distortions.d ← brown_conrady(0.1, 0, 0, 0, 0)
+        Then distort(distortions.d, 0.5, 0) = (0.5125, 0)
+        And undistort(distortions.d, 0.5125, 0) = (0.5, 0)
+    Scenario: Undistorting reverses tangential and radial distortion
+        Given distortions.d ← brown_conrady(-0.2, 0.05, 0.01, 0.002, -0.001)
+        Then undistort(distortions.d) reverses distort(distortions.d, 0.3, -0.4)
+    Scenario: A distorted perspective camera bends rays away from the centre
+        Given camera.c ← camera(201, 101, π/2)
+        And distortions.d ← brown_conrady(0.1, 0, 0, 0, 0)
+        When camera.c.distortion ← distortions.d
+        And ray.r ← ray_for_pixel(camera.c, 100, 50)
+        And ray.s ← ray_for_sample(camera.c, 0, 50, 0.5, 0.5, lens 0, 0)
+        Then ray.r.direction = vector(0, 0, -1)
+        And ray.s.direction = vector(0.67615, 0, -0.73676)
+    Scenario: A closed shutter fires every ray at time 0
+        Given camera.c ← camera(11, 11, π/2)
+        When ray.r ← ray_for_sample(camera.c, 5, 5, 0.5, 0.5, time 0.75)
+        Then ray.r.time = 0
+    Scenario: Sample times are spread across the open shutter
+        Given camera.c ← camera(11, 11, π/2)
+        And camera.c.shutter ← 0.5, 1.5
+        And camera.c.samples ← 8
+        When ray.r ← ray_for_sample(camera.c, 5, 5, 0.5, 0.5, time 0.75)
+        Then ray.r.time = 1.25
+        And camera.c sample times for pixel 5, 5 cover the shutter
+    Scenario: Splitting a crop window into tiles
+        Given camera.c ← camera(11, 7, π/2)
+        And camera.c.tile_size ← 4
+        And camera.c.crop ← (2, 1, 5, 5)
+        Then camera.c has 4 tiles
+    Scenario: Rendering a crop window returns just that region
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.crop ← (4, 4, 3, 3)
+        When canvas.image ← render(camera.c, world.w)
+        Then canvas.image.width = 3
+        And canvas.image.height = 3
+        And pixel_at(canvas.image, 1, 1) = color(0.38066, 0.47583, 0.2855)
+    Scenario: A crop window hanging off the image is clipped
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And camera.c.crop ← (9, -2, 5, 5)
+        When canvas.image ← render(camera.c, world.w)
+        Then canvas.image.width = 2
+        And canvas.image.height = 3
+    Scenario: Re-rendering a crop window into an earlier render
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And canvas.image ← canvas(11, 11)
+        And colors.red ← color(1, 0, 0)
+        And write_pixel(canvas.image, 0, 0, colors.red)
+        And write_pixel(canvas.image, 5, 5, colors.red)
+        And camera.c.crop ← (4, 4, 3, 3)
+        When render_into(camera.c, world.w, canvas.image)
+        Then pixel_at(canvas.image, 0, 0) = colors.red
+        And pixel_at(canvas.image, 5, 5) = color(0.38066, 0.47583, 0.2855)
+    Scenario: A checkpointed render matches a plain render
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        And files.ckpt ← a temporary checkpoint
+        When canvas.plain ← render(camera.c, world.w)
+        And canvas.saved ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        Then canvas.saved = canvas.plain
+        And load_checkpoint(files.ckpt, camera.c, world.w) has 9 tiles
+    Scenario: Resuming a finished render traces nothing
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And camera.c.tile_size ← 4
+        And files.ckpt ← a temporary checkpoint
+        When canvas.first ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        And canvas.second ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        Then canvas.second = canvas.first
+        And camera.c fired 0 camera rays and 0 extra rays
+    Scenario: Resuming retraces a tile cut short by a crash
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And camera.c.tile_size ← 4
+        And camera.c.workers ← 1
+        And files.ckpt ← a temporary checkpoint
+        When canvas.first ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        And files.ckpt loses its last 10 bytes
+        Then load_checkpoint(files.ckpt, camera.c, world.w) has 8 tiles
+        When canvas.second ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        Then canvas.second = canvas.first
+        And camera.c fired 9 camera rays and 0 extra rays
+    Scenario: A checkpoint for a different camera is rejected
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And files.ckpt ← a temporary checkpoint
+        When canvas.first ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        And camera.c.seed ← 7
+        Then load_checkpoint(files.ckpt, camera.c, world.w) is rejected
+        When canvas.second ← render_with_checkpoint(camera.c, world.w, files.ckpt)
+        Then camera.c fired 121 camera rays and 0 extra rays
+    Scenario: Rendering with a progress callback
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And camera.c.tile_size ← 4
+        When canvas.plain ← render(camera.c, world.w)
+        And canvas.image ← render_with_progress(camera.c, world.w)
+        Then canvas.image = canvas.plain
+        And camera.c reported progress 9 times
+        And the progress of camera.c never goes backwards
+        And the last progress of camera.c is 9 of 9 tiles and 121 of 121 pixels
+    Scenario: A cancelled render returns what it had finished
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And camera.c.tile_size ← 4
+        And camera.c.workers ← 1
+        When canvas.image ← render_with_progress(camera.c, world.w) cancelled after 2 tiles
+        Then the render of canvas.image was cancelled
+        And camera.c reported progress 2 times
+        And camera.c fired 32 camera rays and 0 extra rays
+    Scenario: A render cancelled before it starts traces nothing
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        When canvas.image ← render_with_progress(camera.c, world.w) cancelled after 0 tiles
+        Then the render of canvas.image was cancelled
+        And camera.c reported progress 0 times
+        And camera.c fired 0 camera rays and 0 extra rays
+        And every pixel of canvas.image is color(0, 0, 0)
@@ -52,4 +52,41 @@ Feature: Canvas checks
     Scenario: PPM files are terminated by a newline character
         Given canvas.c ← canvas(5, 3)
         When ppm.ppm ← canvas_to_ppm(canvas.c)
-        Then ppm.ppm ends with a newline character
\ No newline at end of file
+        Then ppm.ppm ends with a newline character
+    Scenario: Streaming a PPM matches building it
+        Given canvas.c ← canvas(30, 4)
+        And colors.c1 ← color(1.5, 0, 0)
+        And colors.c2 ← color(0, 0.5, 0)
+        When every pixel of canvas.c is set to color(1, 0.8, 0.6)
+        And write_pixel(canvas.c, 0, 0, colors.c1)
+        And write_pixel(canvas.c, 29, 3, colors.c2)
+        And ppm.built ← canvas_to_ppm(canvas.c)
+        And ppm.streamed ← write_ppm(canvas.c)
+        Then ppm.streamed = ppm.built
+    Scenario: Writing outside a canvas is ignored
+        Given canvas.c ← canvas(3, 2)
+        And colors.red ← color(1, 0, 0)
+        When write_pixel(canvas.c, 3, 0, colors.red)
+        And write_pixel(canvas.c, 0, 2, colors.red)
+        Then every pixel of canvas.c is color(0, 0, 0)
+    Scenario: Reading a row of a canvas
+        Given canvas.c ← canvas(4, 3)
+        And colors.red ← color(1, 0, 0)
+        When write_pixel(canvas.c, 2, 1, colors.red)
+        Then row 1 of canvas.c has 4 pixels
+        And pixel 2 of row 1 of canvas.c = colors.red
+    Scenario: Copying a region out of a canvas
+        Given canvas.c ← canvas(4, 3)
+        And colors.red ← color(1, 0, 0)
+        When write_pixel(canvas.c, 2, 1, colors.red)
+        And canvas.r ← region(canvas.c, 1, 1, 2, 2)
+        Then canvas.r.width = 2
+        And canvas.r.height = 2
+        And pixel_at(canvas.r, 1, 0) = colors.red
+    Scenario: Pasting a region into a canvas
+        Given canvas.c ← canvas(4, 3)
+        And canvas.r ← canvas(2, 2)
+        When every pixel of canvas.r is set to color(1, 0, 0)
+        And set_region(canvas.c, 3, 2, canvas.r)
+        Then pixel_at(canvas.c, 3, 2) = color(1, 0, 0)
+        And pixel_at(canvas.c, 2, 2) = color(0, 0, 0)
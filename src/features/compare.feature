@@ -0,0 +1,74 @@
+Feature: Comparing images
+    Error metrics and difference images between two canvases
+
+    Scenario: Identical canvases do not differ
+        Given canvas.a ← a 8x8 checkerboard of color(1, 1, 1) and color(0, 0, 0)
+        And canvas.b ← a 8x8 checkerboard of color(1, 1, 1) and color(0, 0, 0)
+        When diff.d ← compare(canvas.a, canvas.b)
+        Then diff.d.max_error = 0
+        And diff.d.mean_error = 0
+        And diff.d.psnr is infinite
+        And diff.d.ssim = 1
+
+    Scenario: The error metrics of one changed channel
+        Given canvas.a ← canvas(2, 1)
+        And canvas.b ← canvas(2, 1)
+        And colors.c ← color(0.1, 0, 0)
+        When write_pixel(canvas.b, 1, 0, colors.c)
+        And diff.d ← compare(canvas.a, canvas.b)
+        Then diff.d.max_error = 0.1
+        And diff.d.mean_error = 0.01667
+        And diff.d.psnr = 27.78151
+
+    Scenario: A small brightness shift keeps most of the structure
+        Given canvas.a ← a 12x12 checkerboard of color(0.8, 0.8, 0.8) and color(0.2, 0.2, 0.2)
+        And canvas.b ← a 12x12 checkerboard of color(0.85, 0.85, 0.85) and color(0.25, 0.25, 0.25)
+        When diff.d ← compare(canvas.a, canvas.b)
+        Then diff.d.ssim > 0.99
+        And diff.d.ssim < 1
+
+    Scenario: Inverting an image destroys its structure
+        Given canvas.a ← a 12x12 checkerboard of color(0.8, 0.8, 0.8) and color(0.2, 0.2, 0.2)
+        And canvas.b ← a 12x12 checkerboard of color(0.2, 0.2, 0.2) and color(0.8, 0.8, 0.8)
+        When diff.d ← compare(canvas.a, canvas.b)
+        Then diff.d.ssim < 0
+        And diff.d.max_error = 0.6
+
+    Scenario: Canvases of different sizes cannot be compared
+        Given canvas.a ← canvas(2, 1)
+        And canvas.b ← canvas(1, 2)
+        Then compare(canvas.a, canvas.b) fails
+
+    Scenario: The difference image highlights pixels past the threshold
+        Given canvas.a ← canvas(3, 1)
+        And canvas.b ← canvas(3, 1)
+        And colors.grey ← color(1, 1, 1)
+        And colors.near ← color(1, 1, 0.995)
+        And colors.far ← color(0.5, 1, 1)
+        When write_pixel(canvas.a, 0, 0, colors.grey)
+        And write_pixel(canvas.b, 0, 0, colors.near)
+        And write_pixel(canvas.a, 1, 0, colors.grey)
+        And write_pixel(canvas.b, 1, 0, colors.far)
+        And canvas.d ← diff_image(canvas.a, canvas.b, 0.01)
+        Then pixel_at(canvas.d, 0, 0) = color(0.3, 0.3, 0.3)
+        And pixel_at(canvas.d, 1, 0) = color(0.75, 0, 0)
+        And pixel_at(canvas.d, 2, 0) = color(0, 0, 0)
+
+    Scenario: A render within tolerance of its reference passes
+        Given canvas.a ← a 8x8 checkerboard of color(1, 0.5, 0) and color(0, 0.2, 1)
+        And canvas.b ← a 8x8 checkerboard of color(1, 0.503, 0) and color(0, 0.2, 1)
+        And files.f ← a temporary file "reference.png"
+        When save(canvas.a, files.f)
+        Then check_golden(canvas.b, files.f)
+
+    Scenario: A render that drifts from its reference fails with a diff image
+        Given canvas.a ← a 8x8 checkerboard of color(1, 0.5, 0) and color(0, 0.2, 1)
+        And canvas.b ← a 8x8 checkerboard of color(1, 0.6, 0) and color(0, 0.2, 1)
+        And files.f ← a temporary file "reference.png"
+        When save(canvas.a, files.f)
+        Then check_golden(canvas.b, files.f) fails with a diff image
+
+    Scenario: A render without a reference fails
+        Given canvas.a ← canvas(2, 2)
+        And files.f ← a temporary file "missing.png"
+        Then check_golden(canvas.a, files.f) fails for want of a reference
@@ -0,0 +1,67 @@
+Feature: Denoising
+    A joint bilateral filter guided by the normal, albedo and depth buffers
+
+    Background:
+        Given denoiser.d ← denoiser()
+        And buffers.b ← buffers()
+
+    Scenario: A flat canvas stays flat
+        Given canvas.c ← a 6x6 checkerboard of color(0.3, 0.6, 0.9) and color(0.3, 0.6, 0.9)
+        When canvas.o ← denoise(denoiser.d, canvas.c, buffers.b)
+        Then canvas.o matches canvas.c to within 0.00001
+
+    Scenario: Noise on a flat surface is smoothed away
+        Given canvas.c ← a 9x9 checkerboard of color(0.4, 0.4, 0.4) and color(0.6, 0.6, 0.6)
+        When canvas.o ← denoise(denoiser.d, canvas.c, buffers.b)
+        Then pixel_at(canvas.o, 4, 4) is within 0.02 of color(0.5, 0.5, 0.5)
+        And pixel_at(canvas.o, 3, 4) is within 0.02 of color(0.5, 0.5, 0.5)
+
+    Scenario: Without guides an edge the colour cannot tell apart is blurred
+        Given canvas.c ← a 8x8 canvas of color(0.2, 0.2, 0.2) left of x = 4 and color(0.8, 0.8, 0.8) from there
+        And denoiser.d.color_sigma ← 0
+        When canvas.o ← denoise(denoiser.d, canvas.c, buffers.b)
+        Then pixel_at(canvas.o, 3, 4).red > 0.3
+        And pixel_at(canvas.o, 4, 4).red < 0.7
+
+    Scenario Outline: A guide keeps an edge sharp
+        Given canvas.c ← a 8x8 canvas of color(0.2, 0.2, 0.2) left of x = 4 and color(0.8, 0.8, 0.8) from there
+        And canvas.g ← a 8x8 canvas of <left> left of x = 4 and <right> from there
+        And the "<guide>" buffer of buffers.b ← canvas.g
+        And denoiser.d.color_sigma ← 0
+        When canvas.o ← denoise(denoiser.d, canvas.c, buffers.b)
+        Then canvas.o matches canvas.c to within 0.00001
+
+        Examples:
+            | guide  | left              | right            |
+            | normal | color(0, 0, -1)   | color(1, 0, 0)   |
+            | albedo | color(1, 0, 0)    | color(0, 0, 1)   |
+            | depth  | color(4, 4, 4)    | color(8, 8, 8)   |
+            | depth  | color(0, 0, 0)    | color(5, 5, 5)   |
+
+    Scenario: Noise is smoothed on each side of a guided edge
+        Given canvas.edge ← a 9x9 canvas of color(0.2, 0.2, 0.2) left of x = 4 and color(0.8, 0.8, 0.8) from there
+        And canvas.noise ← a 9x9 checkerboard of color(0, 0, 0) and color(0.1, 0.1, 0.1)
+        And canvas.c ← canvas.edge + canvas.noise
+        And canvas.g ← a 9x9 canvas of color(0, 0, -1) left of x = 4 and color(1, 0, 0) from there
+        And the "normal" buffer of buffers.b ← canvas.g
+        When canvas.o ← denoise(denoiser.d, canvas.c, buffers.b)
+        Then pixel_at(canvas.o, 2, 4) is within 0.02 of color(0.25, 0.25, 0.25)
+        And pixel_at(canvas.o, 3, 4) is within 0.02 of color(0.25, 0.25, 0.25)
+        And pixel_at(canvas.o, 4, 4) is within 0.02 of color(0.85, 0.85, 0.85)
+        And pixel_at(canvas.o, 6, 4) is within 0.02 of color(0.85, 0.85, 0.85)
+
+    Scenario: Guide buffers have to match the canvas
+        Given canvas.c ← canvas(4, 4)
+        And canvas.g ← canvas(4, 2)
+        And the "normal" buffer of buffers.b ← canvas.g
+        Then denoise(denoiser.d, canvas.c, buffers.b) fails
+
+    Scenario: Rendering with the denoiser keeps a smooth surface's shading
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        When canvas.o ← render_denoised(camera.c, world.w, denoiser.d)
+        Then pixel_at(canvas.o, 5, 5) is within 0.01 of color(0.38066, 0.47583, 0.2855)
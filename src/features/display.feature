@@ -0,0 +1,124 @@
+Feature: Display transforms
+    Exposure, tone mapping and sRGB encoding between a render and a screen
+
+    Background:
+        Given canvas.c ← canvas(2, 1)
+        And colors.c1 ← color(1, 3, 0.5)
+        And colors.c2 ← color(0.002, 0, 0.25)
+        And write_pixel(canvas.c, 0, 0, colors.c1)
+        And write_pixel(canvas.c, 1, 0, colors.c2)
+        And display.d ← display_transform()
+
+    Scenario: The default display transform only encodes sRGB
+        When canvas.o ← apply(display.d, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = color(1, 1.61245, 0.73536)
+        And pixel_at(canvas.o, 1, 0) = color(0.02584, 0, 0.53710)
+
+    Scenario: Without sRGB the default display transform changes nothing
+        Given display.d.srgb ← false
+        When canvas.o ← apply(display.d, canvas.c)
+        Then canvas.o = canvas.c
+
+    Scenario: canvas_to_ppm writes the colours as they are
+        Given display.d.srgb ← false
+        When ppm.built ← canvas_to_ppm(canvas.c)
+        And ppm.encoded ← encode(canvas.c, "ppm") with display.d
+        Then ppm.encoded = ppm.built
+
+    Scenario: Saving a PPM goes through the default display transform
+        Given files.f ← a temporary file "out.ppm"
+        When save(canvas.c, files.f)
+        And ppm.p ← the contents of files.f
+        Then lines 4-4 of ppm.p are
+            """
+            255 255 188 7 0 137
+            """
+
+    Scenario: Each stop of exposure doubles the light
+        Given display.d.srgb ← false
+        And display.d.exposure ← 1
+        When canvas.o ← apply(display.d, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = color(2, 6, 1)
+
+    Scenario: Negative exposure darkens
+        Given display.d.srgb ← false
+        And display.d.exposure ← -2
+        When canvas.o ← apply(display.d, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = color(0.25, 0.75, 0.125)
+
+    Scenario Outline: Tone maps squeeze highlights under 1
+        Given display.d.srgb ← false
+        And display.d.tone_map ← "<tone map>"
+        When canvas.o ← apply(display.d, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = <color>
+
+        Examples:
+            | tone map | color                            |
+            | none     | color(1, 3, 0.5)                 |
+            | reinhard | color(0.5, 0.75, 0.33333)        |
+            | filmic   | color(0.49292, 0.83787, 0.30430) |
+            | aces     | color(0.80380, 0.95374, 0.61631) |
+
+    Scenario: sRGB encoding is linear near black and a power curve above
+        Given display.d.srgb ← true
+        When canvas.o ← apply(display.d, canvas.c)
+        Then pixel_at(canvas.o, 1, 0) = color(0.02584, 0, 0.53710)
+        And pixel_at(canvas.o, 0, 0) = color(1, 1.61245, 0.73536)
+
+    Scenario: The stages run in order
+        Given display.d.exposure ← 1
+        And display.d.tone_map ← "reinhard"
+        And display.d.srgb ← true
+        When canvas.o ← apply(display.d, canvas.c)
+        Then pixel_at(canvas.o, 1, 0) = color(0.05053, 0, 0.61250)
+
+    Scenario: The log average luminance is the geometric mean
+        Given canvas.g ← canvas(2, 1)
+        And colors.dim ← color(0.1, 0.1, 0.1)
+        And colors.bright ← color(10, 10, 10)
+        When write_pixel(canvas.g, 0, 0, colors.dim)
+        And write_pixel(canvas.g, 1, 0, colors.bright)
+        Then log_average_luminance(canvas.g) = 1.00049
+
+    Scenario: Auto exposure maps the log average luminance to middle grey
+        Given canvas.g ← canvas(3, 3)
+        And display.d.srgb ← false
+        And display.d.auto_exposure ← true
+        When every pixel of canvas.g is set to color(0.72, 0.72, 0.72)
+        And canvas.o ← apply(display.d, canvas.g)
+        Then pixel_at(canvas.o, 1, 1) = color(0.17998, 0.17998, 0.17998)
+
+    Scenario: Auto exposure and exposure combine
+        Given canvas.g ← canvas(3, 3)
+        And display.d.srgb ← false
+        And display.d.auto_exposure ← true
+        And display.d.exposure ← 1
+        When every pixel of canvas.g is set to color(0.72, 0.72, 0.72)
+        And canvas.o ← apply(display.d, canvas.g)
+        Then pixel_at(canvas.o, 1, 1) = color(0.35995, 0.35995, 0.35995)
+
+    Scenario: Encoders write the display transformed canvas
+        Given display.d.exposure ← 1
+        And canvas.g ← canvas(2, 2)
+        And files.f ← a temporary file "out.png"
+        When every pixel of canvas.g is set to color(0.25, 0.25, 0.25)
+        And save(canvas.g, files.f) with display.d
+        Then the PNG files.f has nrgba(188, 188, 188, 255) at 0, 0
+
+    Scenario: Loading an image decodes sRGB back to linear light
+        Given canvas.g ← canvas(2, 2)
+        And files.f ← a temporary file "out.png"
+        When every pixel of canvas.g is set to color(0.5, 0.5, 0.5)
+        And save(canvas.g, files.f)
+        And canvas.l ← load(files.f)
+        Then the PNG files.f has nrgba(188, 188, 188, 255) at 0, 0
+        And pixel_at(canvas.l, 0, 0) = color(0.50289, 0.50289, 0.50289)
+
+    Scenario: High dynamic range files only get the exposure
+        Given display.d.exposure ← 1
+        And display.d.tone_map ← "aces"
+        And display.d.srgb ← true
+        And files.f ← a temporary file "out.pfm"
+        When save(canvas.c, files.f) with display.d
+        And canvas.l ← load(files.f)
+        Then pixel_at(canvas.l, 0, 0) = color(2, 6, 1)
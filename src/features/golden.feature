@@ -0,0 +1,22 @@
+Feature: Golden images
+    Each chapter's picture, rendered small, matches its reference
+
+    Scenario Outline: A chapter renders as it always has
+        Given canvas.c ← render_chapter("<chapter>", 40, 20)
+        Then canvas.c matches the golden image "golden/<chapter>.png"
+
+        Examples:
+            | chapter   |
+            | chapter02 |
+            | chapter03 |
+            | chapter05 |
+            | chapter06 |
+            | chapter07 |
+            | chapter08 |
+            | chapter09 |
+            | chapter10 |
+            | chapter11 |
+            | chapter12 |
+            | chapter13 |
+            | chapter14 |
+            | chapter15 |
@@ -47,4 +47,31 @@ And set_transform(shapes.s, translation(5, 0, 0))
 And add_child(shapes.g, shapes.s)
 When ray.r ← ray(point(10, 0, -10), vector(0, 0, 1))
 And arrayintersections.xs ← intersect(shapes.g, ray.r)
-Then arrayintersections.xs.count = 2        
\ No newline at end of file
+Then arrayintersections.xs.count = 2        
+
+Scenario: A group's bounds enclose the motion of its children
+Given shapes.g ← group()
+And shapes.s ← sphere()
+And set_end_transform(shapes.s, translation(5, 0, 0))
+And add_child(shapes.g, shapes.s)
+When bounds.b ← bounds(shapes.g)
+Then bounds.b.minpoint = point(-1, -1, -1)
+And bounds.b.maxpoint = point(6, 1, 1)
+
+Scenario: A group's bounds enclose the arcs of a turning child
+Given shapes.g ← group()
+And shapes.s ← sphere()
+And set_end_transform(shapes.s, rotation_y(3))
+And add_child(shapes.g, shapes.s)
+When bounds.b ← bounds(shapes.g)
+Then bounds.b holds point(1, 1, 1) of shapes.s all through the shutter
+And bounds.b holds point(-1, -1, 1) of shapes.s all through the shutter
+
+Scenario: Intersecting a group with a moving child
+Given shapes.g ← group()
+And shapes.s ← sphere()
+And set_end_transform(shapes.s, translation(5, 0, 0))
+And add_child(shapes.g, shapes.s)
+When ray.r ← ray(point(4, 0, -5), vector(0, 0, 1)) at time 0.8
+And arrayintersections.xs ← intersect(shapes.g, ray.r)
+Then arrayintersections.xs.count = 2
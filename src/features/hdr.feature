@@ -0,0 +1,96 @@
+Feature: High dynamic range images
+    Canvases saved and loaded as PFM and Radiance HDR without clamping
+
+    Scenario: A PFM keeps colors outside the displayable range
+        Given canvas.c ← canvas(4, 3)
+        And colors.c1 ← color(2.5, 0.5, -1)
+        And colors.c2 ← color(0.125, 17, 1000)
+        And files.f ← a temporary file "out.pfm"
+        When write_pixel(canvas.c, 0, 0, colors.c1)
+        And write_pixel(canvas.c, 3, 2, colors.c2)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then files.f starts with the bytes 50460a
+        And files.f has 156 bytes
+        And canvas.d matches canvas.c to within 0.000001
+
+    Scenario: A PFM is read bottom row first
+        Given ppm.p ← a file with header "Pf 1 2 1.0" and the bytes 3f80000000000000
+        When canvas.c ← canvas_from_pfm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(0, 0, 0)
+        And pixel_at(canvas.c, 0, 1) = color(1, 1, 1)
+
+    Scenario: A negative PFM scale means little endian floats
+        Given ppm.p ← a file with header "PF\n1 1\n-1.0" and the bytes 000020400000003f000080bf
+        When canvas.c ← canvas_from_pfm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(2.5, 0.5, -1)
+
+    Scenario: A PFM with a bad scale is an error on its line
+        Given ppm.p ← a file with header "PF\n1 1\nbig" and the bytes 000000000000000000000000
+        Then canvas_from_pfm(ppm.p) fails on line 3
+
+    Scenario: A short PFM is an error
+        Given ppm.p ← a file with header "PF\n2 1\n-1.0" and the bytes 000000000000000000000000
+        Then canvas_from_pfm(ppm.p) fails on line 4
+
+    Scenario: A PFM too big to allocate is an error on its line
+        Given ppm.p ← a file with header "PF\n16777216 16777216\n-1.0" and the bytes 000000000000000000000000
+        Then canvas_from_pfm(ppm.p) fails on line 2
+
+    Scenario: A PFM promising more data than it has is an error
+        Given ppm.p ← a file with header "PF\n8192 8192\n-1.0" and the bytes 000000000000000000000000
+        Then canvas_from_pfm(ppm.p) fails on line 4
+
+    Scenario: An HDR keeps bright colors to within its precision
+        Given canvas.c ← canvas(3, 2)
+        And colors.c1 ← color(2.5, 0.5, 0)
+        And colors.c2 ← color(0.01, 0.02, 0.03)
+        And files.f ← a temporary file "out.hdr"
+        When write_pixel(canvas.c, 0, 0, colors.c1)
+        And write_pixel(canvas.c, 2, 1, colors.c2)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then files.f starts with the bytes 233f52414449414e43450a
+        And canvas.d matches canvas.c to within 0.01
+
+    Scenario: Wide HDR scanlines are run length encoded
+        Given canvas.c ← canvas(16, 2)
+        And colors.c1 ← color(3, 2, 1)
+        And files.f ← a temporary file "out.hdr"
+        When every pixel of canvas.c is set to color(0.5, 4, 8)
+        And write_pixel(canvas.c, 5, 1, colors.c1)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then files.f has 86 bytes
+        And canvas.d matches canvas.c to within 0.04
+
+    Scenario: An HDR can repeat pixels with old style runs
+        Given ppm.p ← a file with header "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 3" and the bytes 8040008101010102
+        When canvas.c ← canvas_from_hdr(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(1.00390, 0.50390, 0.00390)
+        And pixel_at(canvas.c, 2, 0) = color(1.00390, 0.50390, 0.00390)
+
+    Scenario: An HDR in another pixel format is an error on its line
+        Given ppm.p ← a file with header "#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1" and the bytes 80400081
+        Then canvas_from_hdr(ppm.p) fails on line 2
+
+    Scenario: A short HDR is an error
+        Given ppm.p ← a file with header "#?RADIANCE\n\n-Y 2 +X 1" and the bytes 80400081
+        Then canvas_from_hdr(ppm.p) fails on line 3
+
+    Scenario: An HDR too big to allocate is an error on its line
+        Given ppm.p ← a file with header "#?RADIANCE\n\n-Y 1000000000 +X 1000000000" and the bytes 80400081
+        Then canvas_from_hdr(ppm.p) fails on line 3
+
+    Scenario: An HDR promising more scanlines than it has fails before allocating them
+        Given ppm.p ← a file with header "#?RADIANCE\n\n-Y 8192 +X 8192" and the bytes 80400081
+        Then canvas_from_hdr(ppm.p) fails having allocated under 64 MB
+
+    Scenario Outline: High dynamic range formats come from the file extension
+        Then image_format("<path>") = "<format>"
+
+        Examples:
+            | path       | format |
+            | out.pfm    | pfm    |
+            | out.hdr    | hdr    |
+            | render.HDR | hdr    |
@@ -0,0 +1,79 @@
+Feature: Image files
+    Canvases as Go images, saved and loaded as PNG, JPEG or PPM
+
+    Scenario: A PNG round trips at 8 bits
+        # Colours are stored sRGB encoded, so these are ones that land on a byte
+        Given canvas.c ← canvas(5, 3)
+        And colors.c1 ← color(1, 0.21586, 0)
+        And colors.c2 ← color(0, 0.50289, 1)
+        And files.f ← a temporary file "out.png"
+        When write_pixel(canvas.c, 0, 0, colors.c1)
+        And write_pixel(canvas.c, 4, 2, colors.c2)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then files.f starts with the bytes 89504e47
+        And canvas.d matches canvas.c to within 0.0001
+
+    Scenario: A 16 bit PNG keeps finer steps than 8 bits
+        Given canvas.c ← canvas(2, 2)
+        And colors.c1 ← color(0.3001, 0.5004, 0.7007)
+        And files.f ← a temporary file "out.png"
+        When write_pixel(canvas.c, 1, 1, colors.c1)
+        And save(canvas.c, files.f) as a 16 bit PNG
+        And canvas.d ← load(files.f)
+        Then canvas.d matches canvas.c to within 0.00001
+
+    Scenario: Colors outside the displayable range are clamped
+        Given canvas.c ← canvas(2, 1)
+        And colors.c1 ← color(1.5, -0.5, 0)
+        And files.f ← a temporary file "out.png"
+        When write_pixel(canvas.c, 0, 0, colors.c1)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then pixel_at(canvas.d, 0, 0) = color(1, 0, 0)
+
+    Scenario: A JPEG is close to the canvas
+        Given canvas.c ← canvas(8, 8)
+        And files.f ← a temporary file "out.jpg"
+        When every pixel of canvas.c is set to color(0.4, 0.6, 0.8)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then files.f starts with the bytes ffd8
+        And canvas.d matches canvas.c to within 0.02
+
+    Scenario: A PPM is chosen by its extension
+        Given canvas.c ← canvas(5, 3)
+        And files.f ← a temporary file "out.ppm"
+        When save(canvas.c, files.f)
+        Then files.f starts with the bytes 50330a
+
+    Scenario Outline: The format comes from the file extension
+        Then image_format("<path>") = "<format>"
+
+        Examples:
+            | path           | format |
+            | out.png        | png    |
+            | render.PNG     | png    |
+            | out.jpg        | jpeg   |
+            | out.jpeg       | jpeg   |
+            | dir.v2/out.ppm | ppm    |
+
+    Scenario: An unknown extension has no format
+        Then image_format("out.tiff") fails
+        And image_format("out") fails
+
+    Scenario: A canvas is built from an image whose bounds are offset
+        Given colors.red ← color(1, 0, 0)
+        When canvas.c ← canvas_from_image(a 3x2 image at (5, 7) with colors.red at (6, 8))
+        Then canvas.c.width = 3
+        And canvas.c.height = 2
+        And pixel_at(canvas.c, 1, 1) = colors.red
+        And pixel_at(canvas.c, 0, 0) = color(0, 0, 0)
+
+    Scenario: A canvas can be drawn onto with the image/draw package
+        Given canvas.a ← canvas(3, 3)
+        And canvas.b ← canvas(2, 2)
+        When every pixel of canvas.b is set to color(0, 0.2, 1)
+        And draw(canvas.a, canvas.b)
+        Then pixel_at(canvas.a, 1, 1) = color(0, 0.2, 1)
+        And pixel_at(canvas.a, 2, 2) = color(0, 0, 0)
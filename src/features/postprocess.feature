@@ -0,0 +1,118 @@
+Feature: Post-processing
+    A stack of effects run on the linear light of a render before it is tone mapped
+
+    Scenario: Effects are read from the command line in order
+        Given post.p ← post_process("bloom:1,2,0.5; vignette:0.3;chromatic:0.01")
+        Then the effects of post.p are "bloom,vignette,chromatic"
+
+    Scenario Outline: Malformed effects are rejected
+        Then post_process("<spec>") fails
+
+        Examples:
+            | spec                  |
+            | glow:1                |
+            | blur                  |
+            | bloom:1,2             |
+            | vignette:half         |
+            | convolve:2,1,0.5,0.5  |
+            | convolve:3,1,1,1      |
+            | chromatic:1           |
+            | chromatic:1.5         |
+            | chromatic:-1          |
+
+    Scenario: The identity kernel changes nothing
+        Given canvas.c ← a 4x4 checkerboard of color(1, 0.5, 0) and color(0, 0.2, 1)
+        And post.p ← post_process("convolve:3,3,0,0,0,0,1,0,0,0,0")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then canvas.o = canvas.c
+
+    Scenario: A kernel weights a pixel's neighbours
+        Given canvas.c ← canvas(3, 1)
+        And colors.c ← color(0.9, 0.6, 0.3)
+        And write_pixel(canvas.c, 1, 0, colors.c)
+        And post.p ← post_process("convolve:3,1,0.25,0.5,0.25")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = color(0.225, 0.15, 0.075)
+        And pixel_at(canvas.o, 1, 0) = color(0.45, 0.3, 0.15)
+        And pixel_at(canvas.o, 2, 0) = color(0.225, 0.15, 0.075)
+
+    Scenario: Blurring a flat canvas leaves it flat
+        Given canvas.c ← a 5x5 checkerboard of color(0.5, 0.5, 0.5) and color(0.5, 0.5, 0.5)
+        And post.p ← post_process("blur:1.5")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = color(0.5, 0.5, 0.5)
+        And pixel_at(canvas.o, 2, 2) = color(0.5, 0.5, 0.5)
+
+    Scenario: Blurring spreads a point of light without losing any
+        Given canvas.c ← canvas(9, 9)
+        And colors.c ← color(1, 1, 1)
+        And write_pixel(canvas.c, 4, 4, colors.c)
+        And post.p ← post_process("blur:1")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 4, 4) = color(0.15924, 0.15924, 0.15924)
+        And pixel_at(canvas.o, 5, 4) = color(0.09658, 0.09658, 0.09658)
+        And the total of canvas.o = color(1, 1, 1)
+
+    Scenario: Sharpening steepens an edge
+        Given canvas.c ← canvas(3, 1)
+        And colors.dark ← color(0.2, 0.2, 0.2)
+        And colors.light ← color(0.8, 0.8, 0.8)
+        And write_pixel(canvas.c, 0, 0, colors.dark)
+        And write_pixel(canvas.c, 1, 0, colors.dark)
+        And write_pixel(canvas.c, 2, 0, colors.light)
+        And post.p ← post_process("sharpen:0.5")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 0, 0) = color(0.2, 0.2, 0.2)
+        And pixel_at(canvas.o, 1, 0) = color(-0.1, -0.1, -0.1)
+        And pixel_at(canvas.o, 2, 0) = color(1.1, 1.1, 1.1)
+
+    Scenario: Bloom leaves a render without highlights alone
+        Given canvas.c ← a 4x4 checkerboard of color(0.9, 0.5, 0) and color(0, 0.2, 1)
+        And post.p ← post_process("bloom:1,2,0.5")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then canvas.o = canvas.c
+
+    Scenario: Bloom makes a highlight glow
+        Given canvas.c ← canvas(9, 9)
+        And colors.c ← color(3, 3, 3)
+        And write_pixel(canvas.c, 4, 4, colors.c)
+        And post.p ← post_process("bloom:1,1,0.5")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 4, 4) = color(3.15924, 3.15924, 3.15924)
+        And pixel_at(canvas.o, 4, 5) = color(0.09658, 0.09658, 0.09658)
+        And the total of canvas.o = color(4, 4, 4)
+
+    Scenario: A vignette darkens towards the corners
+        Given canvas.c ← a 3x3 checkerboard of color(1, 1, 1) and color(1, 1, 1)
+        And post.p ← post_process("vignette:0.9")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 1, 1) = color(1, 1, 1)
+        And pixel_at(canvas.o, 1, 0) = color(0.8, 0.8, 0.8)
+        And pixel_at(canvas.o, 0, 0) = color(0.6, 0.6, 0.6)
+
+    Scenario: Chromatic aberration pulls red and blue apart towards the edges
+        Given canvas.c ← canvas(5, 1)
+        And colors.c0 ← color(0, 0, 0)
+        And colors.c1 ← color(0.25, 0.25, 0.25)
+        And colors.c2 ← color(0.5, 0.5, 0.5)
+        And colors.c3 ← color(0.75, 0.75, 0.75)
+        And colors.c4 ← color(1, 1, 1)
+        And write_pixel(canvas.c, 0, 0, colors.c0)
+        And write_pixel(canvas.c, 1, 0, colors.c1)
+        And write_pixel(canvas.c, 2, 0, colors.c2)
+        And write_pixel(canvas.c, 3, 0, colors.c3)
+        And write_pixel(canvas.c, 4, 0, colors.c4)
+        And post.p ← post_process("chromatic:0.25")
+        When canvas.o ← apply(post.p, canvas.c)
+        Then pixel_at(canvas.o, 2, 0) = color(0.5, 0.5, 0.5)
+        And pixel_at(canvas.o, 0, 0) = color(0.1, 0, 0)
+        And pixel_at(canvas.o, 4, 0) = color(0.9, 1, 1)
+
+    Scenario: Effects run before the display transform when saving
+        Given canvas.c ← a 3x3 checkerboard of color(2, 1, 0.5) and color(0.5, 1, 2)
+        And post.p ← post_process("vignette:0.5;sharpen:0.2")
+        And files.f ← a temporary file "out.pfm"
+        When save(canvas.c, files.f) with post.p
+        And canvas.d ← load(files.f)
+        And canvas.o ← apply(post.p, canvas.c)
+        Then canvas.d matches canvas.o to within 0.0001
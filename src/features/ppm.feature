@@ -0,0 +1,203 @@
+Feature: Reading and writing PPM files
+    Plain (P3) and binary (P6) PPMs loaded into canvases
+
+    Scenario: Reading a file with the wrong magic number
+        Given ppm.p ← a file containing:
+            """
+            P32
+            1 1
+            255
+            0 0 0
+            """
+        Then canvas_from_ppm(ppm.p) fails on line 1
+
+    Scenario: Reading a PPM returns a canvas of the right size
+        Given ppm.p ← a file containing:
+            """
+            P3
+            10 2
+            255
+            0 0 0  0 0 0  0 0 0  0 0 0  0 0 0
+            0 0 0  0 0 0  0 0 0  0 0 0  0 0 0
+            0 0 0  0 0 0  0 0 0  0 0 0  0 0 0
+            0 0 0  0 0 0  0 0 0  0 0 0  0 0 0
+            """
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then canvas.c.width = 10
+        And canvas.c.height = 2
+
+    Scenario Outline: Reading pixel data from a PPM file
+        Given ppm.p ← a file containing:
+            """
+            P3
+            4 3
+            255
+            255 127 0  0 127 255  127 255 0  255 255 255
+            0 0 0  255 0 0  0 255 0  0 0 255
+            255 255 0  0 255 255  255 0 255  127 127 127
+            """
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, <x>, <y>) = <color>
+
+        Examples:
+            | x | y | color                      |
+            | 0 | 0 | color(1, 0.498, 0)         |
+            | 1 | 0 | color(0, 0.498, 1)         |
+            | 2 | 0 | color(0.498, 1, 0)         |
+            | 3 | 0 | color(1, 1, 1)             |
+            | 0 | 1 | color(0, 0, 0)             |
+            | 1 | 1 | color(1, 0, 0)             |
+            | 2 | 1 | color(0, 1, 0)             |
+            | 3 | 1 | color(0, 0, 1)             |
+            | 0 | 2 | color(1, 1, 0)             |
+            | 1 | 2 | color(0, 1, 1)             |
+            | 2 | 2 | color(1, 0, 1)             |
+            | 3 | 2 | color(0.498, 0.498, 0.498) |
+
+    Scenario: PPM parsing ignores comment lines
+        Given ppm.p ← a file containing:
+            """
+            P3
+            # this is a comment
+            2 1
+            # this, too
+            255
+            # another comment
+            255 255 255
+            # oh, no, comments in the pixel data!
+            255 0 255
+            """
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(1, 1, 1)
+        And pixel_at(canvas.c, 1, 0) = color(1, 0, 1)
+
+    Scenario: PPM parsing allows an RGB triple to span lines
+        Given ppm.p ← a file containing:
+            """
+            P3
+            1 1
+            255
+            51
+            153
+
+            204
+            """
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(0.2, 0.6, 0.8)
+
+    Scenario: PPM parsing respects the scale setting
+        Given ppm.p ← a file containing:
+            """
+            P3
+            2 2
+            100
+            100 100 100  50 50 50
+            75 50 25  0 0 0
+            """
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, 0, 1) = color(0.75, 0.5, 0.25)
+
+    Scenario: A sample above the max value is an error on its line
+        Given ppm.p ← a file containing:
+            """
+            P3
+            2 1
+            15
+            0 0 0
+            15 16 0
+            """
+        Then canvas_from_ppm(ppm.p) fails on line 5
+
+    Scenario: A size that is not a number is an error on its line
+        Given ppm.p ← a file containing:
+            """
+            P3
+            # width then height
+            2 x
+            255
+            """
+        Then canvas_from_ppm(ppm.p) fails on line 3
+
+    Scenario: A size too big to allocate is an error on its line
+        Given ppm.p ← a file containing:
+            """
+            P3
+            16777216 16777216
+            255
+            """
+        Then canvas_from_ppm(ppm.p) fails on line 2
+
+    Scenario: A plain PPM promising more samples than it has fails before allocating them
+        Given ppm.p ← a file containing:
+            """
+            P3
+            8192 8192
+            255
+            """
+        Then canvas_from_ppm(ppm.p) fails having allocated under 64 MB
+
+    Scenario: Running out of samples is an error on the last line
+        Given ppm.p ← a file containing:
+            """
+            P3
+            2 1
+            255
+            0 0 0
+            0 0
+            """
+        Then canvas_from_ppm(ppm.p) fails on line 5
+
+    Scenario: Reading a binary PPM
+        Given ppm.p ← a P6 file with header "P6 2 1 255" and the bytes ff8000336699
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(1, 0.50196, 0)
+        And pixel_at(canvas.c, 1, 0) = color(0.2, 0.4, 0.6)
+
+    Scenario: A binary PPM with a large max value uses two bytes a sample
+        Given ppm.p ← a P6 file with header "P6 1 1 65535" and the bytes ffff00008000
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(1, 0, 0.50001)
+
+    Scenario: The raster of a binary PPM may start with a whitespace byte
+        Given ppm.p ← a P6 file with header "P6 1 1 255" and the bytes 0a0d20
+        When canvas.c ← canvas_from_ppm(ppm.p)
+        Then pixel_at(canvas.c, 0, 0) = color(0.03922, 0.05098, 0.12549)
+
+    Scenario: A short binary PPM is an error on the line its data starts
+        Given ppm.p ← a P6 file with header "P6\n2 1\n255" and the bytes ff8000
+        Then canvas_from_ppm(ppm.p) fails on line 4
+
+    Scenario: A binary PPM promising more data than it has is an error
+        Given ppm.p ← a P6 file with header "P6\n8192 8192\n255" and the bytes ff8000
+        Then canvas_from_ppm(ppm.p) fails on line 4
+
+    Scenario: Writing a binary PPM
+        Given canvas.c ← canvas(5, 3)
+        And colors.c1 ← color(1.5, 0, 0)
+        And colors.c2 ← color(0, 0.2, 1)
+        When write_pixel(canvas.c, 0, 0, colors.c1)
+        And write_pixel(canvas.c, 4, 2, colors.c2)
+        And ppm.p ← write_p6(canvas.c)
+        And canvas.d ← canvas_from_ppm(ppm.p)
+        Then ppm.p has 56 bytes
+        And pixel_at(canvas.d, 0, 0) = color(1, 0, 0)
+        And pixel_at(canvas.d, 4, 2) = color(0, 0.2, 1)
+
+    Scenario: A plain PPM round trips through a file
+        Given canvas.c ← canvas(3, 2)
+        And colors.c1 ← color(0.21586, 0.50289, 1)
+        And files.f ← a temporary file "out.ppm"
+        When write_pixel(canvas.c, 2, 1, colors.c1)
+        And save(canvas.c, files.f)
+        And canvas.d ← load(files.f)
+        Then canvas.d matches canvas.c to within 0.0001
+
+    Scenario: A binary PPM round trips through a file
+        Given canvas.c ← canvas(3, 2)
+        And colors.c1 ← color(0.21586, 0.50289, 1)
+        And files.f ← a temporary file "out.ppm"
+        When write_pixel(canvas.c, 2, 1, colors.c1)
+        And save(canvas.c, files.f) as a binary PPM
+        And canvas.d ← load(files.f)
+        Then files.f starts with the bytes 50360a
+        And canvas.d matches canvas.c to within 0.0001
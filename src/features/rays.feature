@@ -26,4 +26,10 @@ Feature: Rays
         And matrix.m ← scaling(2, 3, 4)
         When ray.r2 ← transform(ray.r, matrix.m)
         Then ray.r2.origin = point(2, 6, 12)
-        And ray.r2.direction = vector(0, 3, 0)
\ No newline at end of file
+        And ray.r2.direction = vector(0, 3, 0)
+    Scenario: A ray keeps its time when transformed
+        Given ray.r ← ray(point(1, 2, 3), vector(0, 1, 0)) at time 0.25
+        And matrix.m ← translation(3, 4, 5)
+        When ray.r2 ← transform(ray.r, matrix.m)
+        Then ray.r2.origin = point(4, 6, 8)
+        And ray.r2.time = 0.25
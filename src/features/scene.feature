@@ -0,0 +1,84 @@
+Feature: Scene description
+    A world and camera can be written out and rebuilt in another process
+
+    Scenario: A scene renders the same after a round trip
+        Given world.w ← default_world()
+        And shapes.cyl ← cylinder()
+        And shapes.cyl.minimum ← 1
+        And shapes.cyl.maximum ← 2
+        And shapes.cyl.closed ← true
+        And set_transform(shapes.cyl, translation(0, -2, 2))
+        And shapes.cyl is added to world.w
+        And shapes.g ← group()
+        And shapes.s ← sphere()
+        And set_end_transform(shapes.s, translation(0.5, 0, 0))
+        And add_child(shapes.g, shapes.s)
+        And set_transform(shapes.g, translation(-2, 0, 0))
+        And shapes.g is added to world.w
+        And material.m ← material()
+        And material.m.pattern ← stripe_pattern(color(1, 0, 0), color(0, 0, 1))
+        And shapes.p ← plane()
+        And set_transform(shapes.p, translation(0, -1, 0))
+        And shapes.p.material ← material.m
+        And shapes.p is added to world.w
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 1, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.shutter ← 0, 1
+        And camera.c.samples ← 2
+        When canvas.a ← render(camera.c, world.w)
+        And canvas.b ← render(camera.c, world.w) after a scene round trip
+        Then canvas.b = canvas.a
+
+    Scenario: Test shapes cannot be described
+        Given world.w ← default_world()
+        And shapes.t ← test_shape()
+        And shapes.t is added to world.w
+        And camera.c ← camera(11, 11, π/2)
+        Then describing camera.c and world.w fails
+
+    Scenario: Rendering with loopback workers matches a local render
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        When coordinators.co ← coordinator(camera.c, world.w) on loopback
+        And 3 workers join coordinators.co
+        And canvas.image ← the render from coordinators.co
+        And canvas.plain ← render(camera.c, world.w)
+        Then canvas.image = canvas.plain
+
+    Scenario: A tile held by a worker that dies is traced again
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        When coordinators.co ← coordinator(camera.c, world.w) on loopback
+        And a worker takes a tile from coordinators.co and dies
+        And 1 worker joins coordinators.co
+        And canvas.image ← the render from coordinators.co
+        And canvas.plain ← render(camera.c, world.w)
+        Then canvas.image = canvas.plain
+
+    Scenario: A tile held by a worker that hangs is traced again
+        Given world.w ← default_world()
+        And camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And camera.c.tile_size ← 4
+        When coordinators.co ← coordinator(camera.c, world.w) on loopback with a 200ms tile timeout
+        And a worker takes a tile from coordinators.co and hangs
+        And 1 worker joins coordinators.co
+        And canvas.image ← the render from coordinators.co
+        And canvas.plain ← render(camera.c, world.w)
+        Then canvas.image = canvas.plain
@@ -76,4 +76,32 @@ Feature: Shapes
         And set_transform(shapes.s, translation(5, 0, 0))
         And add_child(shapes.g2, shapes.s)
         When tuple.n ← normal_at(shapes.s, point(1.7321, 1.1547, -5.5774))
-        Then tuple.n = vector(0.2857, 0.4286, -0.8571)
\ No newline at end of file
+        Then tuple.n = vector(0.2857, 0.4286, -0.8571)
+
+    Scenario: A moving shape is halfway along its path at time 0.5
+        Given shapes.s ← test_shape()
+        And set_transform(shapes.s, translation(0, 2, 0))
+        And set_end_transform(shapes.s, translation(4, 2, 0))
+        Then transform_at(shapes.s, 0) = translation(0, 2, 0)
+        And transform_at(shapes.s, 0.5) = translation(2, 2, 0)
+        And transform_at(shapes.s, 1) = translation(4, 2, 0)
+
+    Scenario: A spinning shape turns halfway at time 0.5
+        Given shapes.s ← test_shape()
+        And set_end_transform(shapes.s, rotation_y(1.570796))
+        Then transform_at(shapes.s, 0.5) = rotation_y(0.785398)
+
+    Scenario: A static shape ignores time
+        Given shapes.s ← test_shape()
+        And set_transform(shapes.s, translation(1, 0, 0))
+        Then transform_at(shapes.s, 0.7) = translation(1, 0, 0)
+
+    Scenario: Rays see a moving shape where it is at their time
+        Given shapes.s ← sphere()
+        And set_end_transform(shapes.s, translation(5, 0, 0))
+        When ray.r ← ray(point(5, 0, -5), vector(0, 0, 1)) at time 1
+        And arrayintersections.xs ← intersect(shapes.s, ray.r)
+        Then arrayintersections.xs.count = 2
+        When ray.r ← ray(point(5, 0, -5), vector(0, 0, 1)) at time 0
+        And arrayintersections.xs ← intersect(shapes.s, ray.r)
+        Then arrayintersections.xs.count = 0
@@ -0,0 +1,73 @@
+Feature: Stereo rig
+    Two eyes either side of a camera, composed for a stereo display
+
+    Scenario: The eyes sit either side of the camera
+        Given camera.c ← camera(11, 11, π/2)
+        And stereo.r ← stereo_rig(camera.c, 0.5, 5)
+        When camera.l ← the left eye of stereo.r
+        And camera.rt ← the right eye of stereo.r
+        And ray.l ← ray_for_pixel(camera.l, 5, 5)
+        And ray.rt ← ray_for_pixel(camera.rt, 5, 5)
+        Then ray.l.origin = point(0.25, 0, 0)
+        And ray.rt.origin = point(-0.25, 0, 0)
+        And ray.l.direction = vector(-0.04994, 0, -0.99875)
+        And ray.rt.direction = vector(0.04994, 0, -0.99875)
+
+    Scenario: The eyes converge at the convergence distance
+        Given camera.c ← camera(11, 11, π/2)
+        And tuple.from ← point(1, 2, -5)
+        And tuple.to ← point(1, 2, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And stereo.r ← stereo_rig(camera.c, 0.065, 3)
+        Then the centre rays of stereo.r cross at point(1, 2, -2)
+
+    Scenario: Composing side by side
+        Given canvas.l ← canvas(2, 1)
+        And canvas.rt ← canvas(2, 1)
+        And every pixel of canvas.l is set to color(1, 0, 0)
+        And every pixel of canvas.rt is set to color(0, 0, 1)
+        When canvas.out ← compose_stereo(canvas.l, canvas.rt, "side-by-side")
+        Then canvas.out.width = 4
+        And canvas.out.height = 1
+        And pixel_at(canvas.out, 1, 0) = color(1, 0, 0)
+        And pixel_at(canvas.out, 2, 0) = color(0, 0, 1)
+
+    Scenario: Composing over and under
+        Given canvas.l ← canvas(2, 1)
+        And canvas.rt ← canvas(2, 1)
+        And every pixel of canvas.l is set to color(1, 0, 0)
+        And every pixel of canvas.rt is set to color(0, 0, 1)
+        When canvas.out ← compose_stereo(canvas.l, canvas.rt, "over-under")
+        Then canvas.out.width = 2
+        And canvas.out.height = 2
+        And pixel_at(canvas.out, 1, 0) = color(1, 0, 0)
+        And pixel_at(canvas.out, 1, 1) = color(0, 0, 1)
+
+    Scenario: Composing a red-cyan anaglyph
+        Given canvas.l ← canvas(2, 1)
+        And canvas.rt ← canvas(2, 1)
+        And every pixel of canvas.l is set to color(0.9, 0.8, 0.7)
+        And every pixel of canvas.rt is set to color(0.2, 0.4, 0.6)
+        When canvas.out ← compose_stereo(canvas.l, canvas.rt, "anaglyph")
+        Then canvas.out.width = 2
+        And pixel_at(canvas.out, 0, 0) = color(0.9, 0.4, 0.6)
+
+    Scenario: Views of different sizes cannot be composed
+        Given canvas.l ← canvas(2, 1)
+        And canvas.rt ← canvas(3, 1)
+        Then compose_stereo(canvas.l, canvas.rt, "anaglyph") fails
+
+    Scenario: Rendering a stereo pair
+        Given world.w ← default_world()
+        And camera.c ← camera(5, 5, π/2)
+        And tuple.from ← point(0, 0, -5)
+        And tuple.to ← point(0, 0, 0)
+        And tuple.up ← vector(0, 1, 0)
+        And camera.c.transform ← view_transform(tuple.from, tuple.to, tuple.up)
+        And stereo.r ← stereo_rig(camera.c, 0.1, 5)
+        When canvas.out ← render_stereo(stereo.r, world.w, "side-by-side")
+        Then canvas.out.width = 10
+        And canvas.out.height = 5
+        And pixel_at(canvas.out, 2, 2) = color(0.38409, 0.48011, 0.28807)
+        And pixel_at(canvas.out, 7, 2) = color(0.37720, 0.47149, 0.28290)
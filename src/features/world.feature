@@ -37,6 +37,19 @@ Feature: TOMORROW THE WORLD
         And ray.r ← ray(point(0, 0, -5), vector(0, 0, 1))
         When colors.c ← color_at(world.w, ray.r)
         Then colors.c = color(0.38066, 0.47583, 0.2855)
+    Scenario: Tracing one world from many goroutines
+        # Run under `go test -race` to have the race detector watch the shared world
+        Given world.w ← default_world()
+        And shapes.g ← group()
+        And shapes.t ← test_shape()
+        And shapes.s ← sphere()
+        And set_transform(shapes.s, translation(5, 0, 0))
+        And add_child(shapes.g, shapes.t)
+        And add_child(shapes.g, shapes.s)
+        And shapes.g is added to world.w
+        And ray.r ← ray(point(0, 0, -5), vector(0, 0, 1))
+        When colors.c ← color_at(world.w, ray.r) from 16 goroutines
+        Then colors.c = color(0.38066, 0.47583, 0.2855)
     Scenario: There is no shadow when nothing is collinear with point and light
         Given world.w ← default_world()
         And tuple.p ← point(0, 10, 0)
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GoldenChapters are the chapters with a picture to check against a
+// reference render.
+var GoldenChapters = []string{
+	"chapter02", "chapter03", "chapter05", "chapter06", "chapter07", "chapter08",
+	"chapter09", "chapter10", "chapter11", "chapter12", "chapter13", "chapter14",
+	"chapter15",
+}
+
+// ChapterFixtures is the directory RenderChapter reads models from.
+var ChapterFixtures = "fixtures"
+
+// RenderChapter draws a chapter's picture. Scenes are rendered hsize by
+// vsize and the sphere casts of chapters five and six hsize square; the
+// plots of chapters two and three have fixed sizes.
+func RenderChapter(name string, hsize, vsize int64) (Canvas, error) {
+	var world World
+	var camera Camera
+	switch name {
+	case "chapter02":
+		return ChapterTwoCanvas(), nil
+	case "chapter03":
+		return ChapterThreeCanvas(), nil
+	case "chapter05":
+		return ChapterFiveCanvas(int(hsize)), nil
+	case "chapter06":
+		return ChapterSixCanvas(int(hsize)), nil
+	case "chapter07":
+		world, camera = ChapterSevenScene(hsize, vsize)
+	case "chapter08":
+		world, camera = ChapterEightScene(hsize, vsize)
+	case "chapter09":
+		world, camera = ChapterNineScene(hsize, vsize)
+	case "chapter10":
+		world, camera = ChapterTenScene(hsize, vsize)
+	case "chapter11":
+		world, camera = ChapterElevenScene(hsize, vsize)
+	case "chapter12":
+		world, camera = ChapterTwelveScene(hsize, vsize)
+	case "chapter13":
+		world, camera = ChapterThirteenScene(hsize, vsize)
+	case "chapter14":
+		world, camera = ChapterFourteenScene(hsize, vsize)
+	case "chapter15":
+		world, camera = ChapterFifteenScene(hsize, vsize, filepath.Join(ChapterFixtures, "teapot.obj"))
+		// The chapter's camera is too close to see the teapot at this size,
+		// so the reference looks at it from further back
+		camera.SetTransform(ViewTransform(NewPoint(0, 4, -8), NewPoint(0, 1.4, 0), NewVector(0, 1, 0)))
+	default:
+		return Canvas{}, fmt.Errorf("no chapter %q", name)
+	}
+	return camera.Render(world), nil
+}
+
+// GoldenTolerance is how far a render may drift from its reference before
+// the check fails. References are 8 bit images, so MaxError needs to allow
+// for at least half a step of 1/255.
+type GoldenTolerance struct {
+	MaxError float64
+	MinPSNR  float64
+}
+
+func NewGoldenTolerance() GoldenTolerance {
+	return GoldenTolerance{MaxError: 0.01, MinPSNR: 40}
+}
+
+// CheckGolden compares a render with the reference image at path, or with
+// update set saves the render as the new reference. When the render is out
+// of tolerance a diff image is written to the system temp directory and
+// named in the error.
+func CheckGolden(render Canvas, path string, tol GoldenTolerance, update bool) (ImageDiff, error) {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return ImageDiff{}, err
+		}
+		return ImageDiff{}, render.Save(path)
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ImageDiff{}, fmt.Errorf("%s has no reference image yet", path)
+	}
+	reference, err := LoadCanvas(path)
+	if err != nil {
+		return ImageDiff{}, err
+	}
+	// The reference was clamped to [0, 1] when it was saved
+	render = clampCanvas(render)
+	diff, err := CompareCanvases(reference, render)
+	if err != nil {
+		return diff, fmt.Errorf("%s: %v", path, err)
+	}
+	if diff.MaxError <= tol.MaxError && diff.PSNR >= tol.MinPSNR {
+		return diff, nil
+	}
+	img, err := DiffImage(reference, render, tol.MaxError)
+	if err != nil {
+		return diff, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "-diff.png"
+	diffPath := filepath.Join(os.TempDir(), name)
+	if err := img.Save(diffPath); err != nil {
+		return diff, err
+	}
+	return diff, fmt.Errorf("%s differs from its reference (%v); see %s", path, diff, diffPath)
+}
+
+func clampCanvas(c Canvas) Canvas {
+	out := NewCanvas(c.Width, c.Height)
+	for i, col := range c.Pixels {
+		out.Pixels[i] = NewColor(
+			math.Min(math.Max(col.Red, 0), 1),
+			math.Min(math.Max(col.Green, 0), 1),
+			math.Min(math.Max(col.Blue, 0), 1))
+	}
+	return out
+}
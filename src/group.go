@@ -1,13 +1,14 @@
 package main
 
 import (
-	"fmt"
 	"math"
 	"sort"
+	"sync"
 )
 
 type Group struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
@@ -18,14 +19,13 @@ type Group struct {
 	Shapes    []Shaper
 	Parent    *Group
 	MyBounds  *Bounds
-}
 
-var groupIdNumber = 0
+	boundsOnce sync.Once
+}
 
 func NewGroup() *Group {
-	groupIdNumber++
 	return &Group{
-		ID:        groupIdNumber,
+		ID:        nextShapeID(),
 		Transform: IdentityMatrix(),
 		Origin:    NewPoint(0, 0, 0),
 		Material:  NewMaterial(),
@@ -103,21 +103,13 @@ func (s *Group) NormalToWorld(p Tuple) Tuple {
 }
 func (s *Group) LocalIntersects(r Ray) map[int]Intersection {
 	xs := make(map[int]Intersection)
-	if s.MyBounds == nil {
-		s.MyBounds = s.Bounds()
-	}
-	xx := s.MyBounds.Intersects(r)
+	xx := s.cachedBounds().Intersects(r)
 	if len(xx) == 0 {
 		return xs
 	}
-	oks := 0
-	mep := ""
 	for _, o := range s.Shapes {
-		xs2 := o.Intersects(r)
-		mep = mep + fmt.Sprintf("Looking at shape %d\n", o.GetID())
-		for _, b := range xs2 {
+		for _, b := range o.Intersects(r) {
 			xs[len(xs)] = b
-			oks++
 		}
 	}
 
@@ -136,6 +128,17 @@ func (s *Group) LocalIntersects(r Ray) map[int]Intersection {
 	return xs
 }
 
+// cachedBounds computes the group's bounds on first use. Groups are shared
+// between render workers, and once filled the bounds are read without a lock.
+func (s *Group) cachedBounds() *Bounds {
+	s.boundsOnce.Do(func() {
+		if s.MyBounds == nil {
+			s.MyBounds = s.Bounds()
+		}
+	})
+	return s.MyBounds
+}
+
 func (s *Group) NormalAt(p Tuple) Tuple {
 	localPoint := s.WorldToObject(p)
 	localNormal := s.LocalNormalAt(localPoint)
@@ -147,18 +150,25 @@ func (s *Group) Bounds() *Bounds {
 	// Check each containing object
 	for _, o := range s.Shapes {
 		oB := o.Bounds().AsCube()
-		d := o.GetTransform()
-		for _, c := range oB {
-			// Convert to Object Space
-			c = d.MultiplyTuple(c)
-			b.Minimum.X = math.Min(c.X, b.Minimum.X)
-			b.Minimum.Y = math.Min(c.Y, b.Minimum.Y)
-			b.Minimum.Z = math.Min(c.Z, b.Minimum.Z)
-			b.Maximum.X = math.Max(c.X, b.Maximum.X)
-			b.Maximum.Y = math.Max(c.Y, b.Maximum.Y)
-			b.Maximum.Z = math.Max(c.Z, b.Maximum.Z)
+		// Moving children are bounded everywhere they go during the shutter
+		pad := motionPadding(o, oB)
+		for _, d := range motionSamples(o) {
+			for _, c := range oB {
+				// Convert to Object Space
+				c = d.MultiplyTuple(c)
+				b.Minimum.X = math.Min(c.X-pad, b.Minimum.X)
+				b.Minimum.Y = math.Min(c.Y-pad, b.Minimum.Y)
+				b.Minimum.Z = math.Min(c.Z-pad, b.Minimum.Z)
+				b.Maximum.X = math.Max(c.X+pad, b.Maximum.X)
+				b.Maximum.Y = math.Max(c.Y+pad, b.Maximum.Y)
+				b.Maximum.Z = math.Max(c.Z+pad, b.Maximum.Z)
+			}
 		}
 	}
 
 	return b
 }
+
+func (s *Group) GetParent() *Group {
+	return s.Parent
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const hdrFormat = "32-bit_rle_rgbe"
+
+// toRGBE packs a colour into three 8 bit mantissas sharing an exponent, as
+// Radiance does. Negative channels become zero.
+func toRGBE(col Color) [4]byte {
+	r, g, b := math.Max(col.Red, 0), math.Max(col.Green, 0), math.Max(col.Blue, 0)
+	v := math.Max(r, math.Max(g, b))
+	if v < 1e-32 {
+		return [4]byte{}
+	}
+	m, e := math.Frexp(v)
+	scale := m * 256 / v
+	return [4]byte{byte(r * scale), byte(g * scale), byte(b * scale), byte(e + 128)}
+}
+
+func fromRGBE(p [4]byte) Color {
+	if p[3] == 0 {
+		return NewColor(0, 0, 0)
+	}
+	f := math.Ldexp(1, int(p[3])-(128+8))
+	return NewColor((float64(p[0])+0.5)*f, (float64(p[1])+0.5)*f, (float64(p[2])+0.5)*f)
+}
+
+// WriteHDR writes the canvas as a Radiance RGBE picture, run length encoding
+// each scanline wide enough to allow it.
+func (c *Canvas) WriteHDR(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "#?RADIANCE\nFORMAT=%s\n\n-Y %d +X %d\n", hdrFormat, c.Height, c.Width)
+	scan := make([][4]byte, c.Width)
+	channel := make([]byte, c.Width)
+	for y := 0; y < c.Height; y++ {
+		for x, col := range c.Row(y) {
+			scan[x] = toRGBE(col)
+		}
+		if c.Width < 8 || c.Width > 0x7fff {
+			for _, p := range scan {
+				out.Write(p[:])
+			}
+			continue
+		}
+		out.Write([]byte{2, 2, byte(c.Width >> 8), byte(c.Width & 0xff)})
+		for i := 0; i < 4; i++ {
+			for x, p := range scan {
+				channel[x] = p[i]
+			}
+			writeHDRRuns(out, channel)
+		}
+	}
+	return out.Flush()
+}
+
+// writeHDRRuns encodes one channel of a scanline as runs of a repeated byte
+// (a count above 128) and literal stretches (a count up to 128).
+func writeHDRRuns(out *bufio.Writer, data []byte) {
+	const minRun = 4
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && run < 127 && data[i+run] == data[i] {
+			run++
+		}
+		if run >= minRun {
+			out.WriteByte(byte(128 + run))
+			out.WriteByte(data[i])
+			i += run
+			continue
+		}
+		// Copy literally up to the next run worth encoding
+		end := i
+		for end < len(data) && end-i < 128 {
+			next := 1
+			for end+next < len(data) && next < minRun && data[end+next] == data[end] {
+				next++
+			}
+			if next >= minRun {
+				break
+			}
+			end++
+		}
+		out.WriteByte(byte(end - i))
+		out.Write(data[i:end])
+		i = end
+	}
+}
+
+// ReadHDR decodes a Radiance RGBE picture stored top to bottom, left to
+// right, which is how nearly every tool writes them. Flat, old style run
+// length and new style run length scanlines are all understood.
+func ReadHDR(r io.Reader) (Canvas, error) {
+	in := bufio.NewReader(r)
+	line := 0
+	fail := func(format string, args ...interface{}) error {
+		return &ImageFileError{Format: "hdr", Line: line, Msg: fmt.Sprintf(format, args...)}
+	}
+	readLine := func() (string, error) {
+		line++
+		s, err := in.ReadString('\n')
+		if err == io.EOF && s != "" {
+			err = nil
+		}
+		if err == io.EOF {
+			return "", fail("file ends in the header")
+		}
+		return strings.TrimRight(s, "\r\n"), err
+	}
+
+	magic, err := readLine()
+	if err != nil {
+		return Canvas{}, err
+	}
+	if magic != "#?RADIANCE" && magic != "#?RGBE" {
+		return Canvas{}, fail("%q is not a Radiance header", magic)
+	}
+	for {
+		l, err := readLine()
+		if err != nil {
+			return Canvas{}, err
+		}
+		if l == "" {
+			break
+		}
+		if strings.HasPrefix(l, "FORMAT=") && l != "FORMAT="+hdrFormat {
+			return Canvas{}, fail("unsupported %s", l)
+		}
+	}
+	res, err := readLine()
+	if err != nil {
+		return Canvas{}, err
+	}
+	var width, height int
+	if n, _ := fmt.Sscanf(res, "-Y %d +X %d", &height, &width); n != 2 || width < 0 || height < 0 {
+		return Canvas{}, fail("unsupported resolution %q", res)
+	}
+	if width > 1<<24 || height > 1<<24 || width*height > MaxImagePixels {
+		return Canvas{}, fail("%dx%d is more than %d pixels", width, height, MaxImagePixels)
+	}
+
+	// As with PFM, the pixels grow a scanline at a time rather than being
+	// sized from the header
+	pixels := []Color{}
+	scan := make([][4]byte, width)
+	for y := 0; y < height; y++ {
+		if err := readHDRScanline(in, scan); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return Canvas{}, fail("image data ends in scanline %d", y)
+			}
+			return Canvas{}, fail("scanline %d: %v", y, err)
+		}
+		for _, p := range scan {
+			pixels = append(pixels, fromRGBE(p))
+		}
+	}
+	return Canvas{Width: width, Height: height, Pixels: pixels}, nil
+}
+
+func readHDRScanline(in *bufio.Reader, scan [][4]byte) error {
+	width := len(scan)
+	if width == 0 {
+		return nil
+	}
+	first := [4]byte{}
+	if _, err := io.ReadFull(in, first[:]); err != nil {
+		return err
+	}
+	if width < 8 || width > 0x7fff || first[0] != 2 || first[1] != 2 || first[2]&0x80 != 0 {
+		return readHDRFlat(in, scan, first)
+	}
+	if int(first[2])<<8|int(first[3]) != width {
+		return fmt.Errorf("run length scanline is %d wide, not %d", int(first[2])<<8|int(first[3]), width)
+	}
+	for i := 0; i < 4; i++ {
+		for x := 0; x < width; {
+			count, err := in.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				n := int(count) - 128
+				v, err := in.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+n > width {
+					return fmt.Errorf("run overflows the scanline")
+				}
+				for ; n > 0; n-- {
+					scan[x][i] = v
+					x++
+				}
+				continue
+			}
+			n := int(count)
+			if n == 0 || x+n > width {
+				return fmt.Errorf("bad literal count %d", n)
+			}
+			for ; n > 0; n-- {
+				v, err := in.ReadByte()
+				if err != nil {
+					return err
+				}
+				scan[x][i] = v
+				x++
+			}
+		}
+	}
+	return nil
+}
+
+// readHDRFlat reads uncompressed pixels, expanding the old style runs where
+// a pixel of 1, 1, 1, n repeats the one before it.
+func readHDRFlat(in *bufio.Reader, scan [][4]byte, first [4]byte) error {
+	shift := uint(0)
+	p := first
+	for x := 0; ; {
+		if p[0] == 1 && p[1] == 1 && p[2] == 1 {
+			if x == 0 {
+				return fmt.Errorf("scanline starts with a repeat")
+			}
+			n := int(p[3]) << shift
+			if x+n > len(scan) {
+				return fmt.Errorf("repeat overflows the scanline")
+			}
+			for ; n > 0; n-- {
+				scan[x] = scan[x-1]
+				x++
+			}
+			shift += 8
+		} else {
+			scan[x] = p
+			x++
+			shift = 0
+		}
+		if x == len(scan) {
+			return nil
+		}
+		if _, err := io.ReadFull(in, p[:]); err != nil {
+			return err
+		}
+	}
+}
+
+// LoadHDR reads a Radiance picture file into a canvas.
+func LoadHDR(path string) (Canvas, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Canvas{}, err
+	}
+	defer f.Close()
+	return ReadHDR(f)
+}
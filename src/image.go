@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ draw.Image = (*Canvas)(nil)
+
+// A *Canvas is a draw.Image. Colours are read out clamped to [0, 1] at 16
+// bits a channel, and anything drawn in is stored as linear floats. Both
+// ways colours are premultiplied, and alpha is kept if the canvas has it.
+func (c *Canvas) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (c *Canvas) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.Width, c.Height)
+}
+
+func (c *Canvas) At(x, y int) color.Color {
+	col := c.PixelAt(x, y)
+	a := colorToDepth(c.AlphaAt(x, y), 0xffff)
+	// Premultiplied channels cannot be brighter than the alpha
+	channel := func(v float64) uint16 {
+		return uint16(math.Min(float64(colorToDepth(v, 0xffff)), float64(a)))
+	}
+	return color.RGBA64{R: channel(col.Red), G: channel(col.Green), B: channel(col.Blue), A: uint16(a)}
+}
+
+func (c *Canvas) Set(x, y int, col color.Color) {
+	r, g, b, a := col.RGBA()
+	c.WritePixel(x, y, NewColor(float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff))
+	c.WriteAlpha(x, y, float64(a)/0xffff)
+}
+
+// NewCanvasFromImage copies any image into a canvas the size of its bounds,
+// with the top left corner of the bounds at (0, 0). The image is taken to be
+// sRGB encoded, as the encoders write it, and is decoded to linear light.
+// Images that are not opaque give the canvas alpha.
+func NewCanvasFromImage(img image.Image) Canvas {
+	b := img.Bounds()
+	c := NewCanvas(b.Dx(), b.Dy())
+	if o, ok := img.(interface{ Opaque() bool }); !ok || !o.Opaque() {
+		c.AddAlpha()
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c.Set(x-b.Min.X, y-b.Min.Y, img.At(x, y))
+		}
+	}
+	c.decodeSRGB()
+	return c
+}
+
+// ImageFormats are the formats Save can write, named as ImageFormat
+// returns them.
+var ImageFormats = []string{"ppm", "png", "jpeg", "pfm", "hdr"}
+
+// ImageFormat picks the format for a file from its extension.
+func ImageFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ppm":
+		return "ppm", nil
+	case ".png":
+		return "png", nil
+	case ".jpg", ".jpeg":
+		return "jpeg", nil
+	case ".pfm":
+		return "pfm", nil
+	case ".hdr":
+		return "hdr", nil
+	}
+	return "", fmt.Errorf("no image format for %q", path)
+}
+
+// EncodeOptions tune the formats that have settings. PNGDepth is 8 or 16
+// bits a channel, JPEGQuality runs from 1 to 100 and PPMBinary writes P6
+// rather than P3. PostProcess and then Display are applied before any
+// format is written. AlphaMode is one of AlphaModes, and says how a PNG
+// keeps the alpha of a canvas that has it; other formats show the canvas
+// over black.
+type EncodeOptions struct {
+	PNGDepth    int
+	JPEGQuality int
+	PPMBinary   bool
+	PostProcess PostProcess
+	Display     DisplayTransform
+	AlphaMode   string
+}
+
+// AlphaModes: "straight" stores colours divided by their alpha, as PNG
+// readers expect; "premultiplied" stores colours as the canvas holds them,
+// for tools that composite premultiplied images; "none" writes an opaque
+// PNG over black.
+var AlphaModes = []string{"straight", "premultiplied", "none"}
+
+func NewEncodeOptions() EncodeOptions {
+	return EncodeOptions{
+		PNGDepth:    8,
+		JPEGQuality: 90,
+		Display:     NewDisplayTransform(),
+		AlphaMode:   "straight",
+	}
+}
+
+// Encode writes the canvas to w in one of ImageFormats.
+func (c *Canvas) Encode(w io.Writer, format string, opts EncodeOptions) error {
+	c = c.forDisplay(format, opts)
+	switch format {
+	case "ppm":
+		if opts.PPMBinary {
+			return c.WriteP6(w)
+		}
+		return c.WritePPM(w)
+	case "png":
+		return c.writePNG(w, opts.PNGDepth, c.HasAlpha() && opts.AlphaMode != "none")
+	case "jpeg":
+		return c.writeJPEG(w, opts.JPEGQuality)
+	case "pfm":
+		return c.WritePFM(w)
+	case "hdr":
+		return c.WriteHDR(w)
+	}
+	return fmt.Errorf("unknown image format %q", format)
+}
+
+// forDisplay runs opts' PostProcess and Display over the canvas, or returns
+// it as it is when there is nothing to run. Effects work on the
+// premultiplied colours and move alpha along with them. A PNG keeping
+// alpha has its colours divided by alpha for the display transform, and
+// multiplied back afterwards if it is to be premultiplied.
+func (c *Canvas) forDisplay(format string, opts EncodeOptions) *Canvas {
+	out := c
+	if len(opts.PostProcess) > 0 {
+		processed := opts.PostProcess.Apply(out)
+		out = &processed
+	}
+	keepAlpha := format == "png" && out.HasAlpha() && opts.AlphaMode != "none"
+	if keepAlpha && (opts.AlphaMode != "premultiplied" || !opts.Display.IsIdentity()) {
+		straight := out.unpremultiplied()
+		out = &straight
+	}
+	if !opts.Display.IsIdentity() {
+		var displayed Canvas
+		// PFM and HDR keep linear light, so only get the exposure
+		if format == "pfm" || format == "hdr" {
+			displayed = opts.Display.ScaleOnly(out)
+		} else {
+			displayed = opts.Display.Apply(out)
+		}
+		displayed.Alpha = out.Alpha
+		out = &displayed
+	}
+	if keepAlpha && opts.AlphaMode == "premultiplied" && !opts.Display.IsIdentity() {
+		premultiplied := out.premultiplied()
+		out = &premultiplied
+	}
+	return out
+}
+
+// unpremultiplied divides each colour by its alpha; fully transparent
+// pixels are black.
+func (c *Canvas) unpremultiplied() Canvas {
+	out := NewCanvas(c.Width, c.Height)
+	out.Alpha = c.Alpha
+	for i, col := range c.Pixels {
+		if a := c.Alpha[i]; a > 0 {
+			out.Pixels[i] = col.MultiplyScalar(1 / a)
+		}
+	}
+	return out
+}
+
+func (c *Canvas) premultiplied() Canvas {
+	out := NewCanvas(c.Width, c.Height)
+	out.Alpha = c.Alpha
+	for i, col := range c.Pixels {
+		out.Pixels[i] = col.MultiplyScalar(c.Alpha[i])
+	}
+	return out
+}
+
+// WritePNG writes the canvas through the default display transform, with
+// straight alpha if it has alpha.
+func (c *Canvas) WritePNG(w io.Writer, depth int) error {
+	opts := NewEncodeOptions()
+	opts.PNGDepth = depth
+	return c.Encode(w, "png", opts)
+}
+
+// writePNG stores the canvas's colours as they are, with its alpha
+// alongside them if alpha is set.
+func (c *Canvas) writePNG(w io.Writer, depth int, alpha bool) error {
+	switch {
+	case depth == 8 && alpha:
+		return png.Encode(w, c.toNRGBA())
+	case depth == 8:
+		return png.Encode(w, c.toRGBA())
+	case depth == 16 && alpha:
+		return png.Encode(w, c.toNRGBA64())
+	case depth == 16:
+		return png.Encode(w, c.toRGBA64())
+	}
+	return fmt.Errorf("PNG depth must be 8 or 16, not %d", depth)
+}
+
+// WriteJPEG writes the canvas through the default display transform.
+func (c *Canvas) WriteJPEG(w io.Writer, quality int) error {
+	opts := NewEncodeOptions()
+	opts.JPEGQuality = quality
+	return c.Encode(w, "jpeg", opts)
+}
+
+func (c *Canvas) writeJPEG(w io.Writer, quality int) error {
+	return jpeg.Encode(w, c.toRGBA(), &jpeg.Options{Quality: quality})
+}
+
+func (c *Canvas) toRGBA() *image.RGBA {
+	img := image.NewRGBA(c.Bounds())
+	for y := 0; y < c.Height; y++ {
+		for x, col := range c.Row(y) {
+			i := img.PixOffset(x, y)
+			img.Pix[i] = uint8(colorToDepth(col.Red, 255))
+			img.Pix[i+1] = uint8(colorToDepth(col.Green, 255))
+			img.Pix[i+2] = uint8(colorToDepth(col.Blue, 255))
+			img.Pix[i+3] = 255
+		}
+	}
+	return img
+}
+
+func (c *Canvas) toRGBA64() *image.RGBA64 {
+	img := image.NewRGBA64(c.Bounds())
+	for y := 0; y < c.Height; y++ {
+		for x, col := range c.Row(y) {
+			img.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(colorToDepth(col.Red, 0xffff)),
+				G: uint16(colorToDepth(col.Green, 0xffff)),
+				B: uint16(colorToDepth(col.Blue, 0xffff)),
+				A: 0xffff,
+			})
+		}
+	}
+	return img
+}
+
+func (c *Canvas) toNRGBA() *image.NRGBA {
+	img := image.NewNRGBA(c.Bounds())
+	for y := 0; y < c.Height; y++ {
+		for x, col := range c.Row(y) {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(colorToDepth(col.Red, 255)),
+				G: uint8(colorToDepth(col.Green, 255)),
+				B: uint8(colorToDepth(col.Blue, 255)),
+				A: uint8(colorToDepth(c.AlphaAt(x, y), 255)),
+			})
+		}
+	}
+	return img
+}
+
+func (c *Canvas) toNRGBA64() *image.NRGBA64 {
+	img := image.NewNRGBA64(c.Bounds())
+	for y := 0; y < c.Height; y++ {
+		for x, col := range c.Row(y) {
+			img.SetNRGBA64(x, y, color.NRGBA64{
+				R: uint16(colorToDepth(col.Red, 0xffff)),
+				G: uint16(colorToDepth(col.Green, 0xffff)),
+				B: uint16(colorToDepth(col.Blue, 0xffff)),
+				A: uint16(colorToDepth(c.AlphaAt(x, y), 0xffff)),
+			})
+		}
+	}
+	return img
+}
+
+// Save writes the canvas to path in the format its extension names, with
+// the default EncodeOptions.
+func (c *Canvas) Save(path string) error {
+	return c.SaveWith(path, NewEncodeOptions())
+}
+
+func (c *Canvas) SaveWith(path string, opts EncodeOptions) error {
+	format, err := ImageFormat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(f)
+	err = c.Encode(out, format, opts)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// LoadCanvas decodes a file in any of ImageFormats into a canvas. PPM, PFM
+// and HDR files are recognised by their extension, PNG and JPEG by their
+// contents. PPM, PNG and JPEG files are decoded from sRGB to linear light.
+func LoadCanvas(path string) (Canvas, error) {
+	format, _ := ImageFormat(path)
+	switch format {
+	case "ppm":
+		c, err := LoadPPM(path)
+		if err == nil {
+			c.decodeSRGB()
+		}
+		return c, err
+	case "pfm":
+		return LoadPFM(path)
+	case "hdr":
+		return LoadHDR(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Canvas{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(bufio.NewReader(f))
+	if err != nil {
+		return Canvas{}, err
+	}
+	return NewCanvasFromImage(img), nil
+}
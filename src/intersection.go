@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"math"
+	"sync/atomic"
 )
 
 type Intersection struct {
@@ -11,11 +12,10 @@ type Intersection struct {
 	Object Shaper
 }
 
-var intersectionCount = 0
+var intersectionCount int64
 
 func NewIntersection(t float64, shape Shaper) Intersection {
-	intersectionCount++
-	return Intersection{ID: intersectionCount, T: t, Object: shape}
+	return Intersection{ID: int(atomic.AddInt64(&intersectionCount, 1)), T: t, Object: shape}
 }
 
 func (i *Intersection) ObjectEquals(target Shaper) bool {
@@ -49,6 +49,7 @@ func Hit(inters map[int]Intersection) (bool, Intersection) {
 
 type Computations struct {
 	T          float64
+	Time       float64
 	Object     Shaper
 	Point      Tuple
 	Eyev       Tuple
@@ -64,11 +65,12 @@ type Computations struct {
 func (i *Intersection) PrepareComputations(r Ray, xs map[int]Intersection) Computations {
 	comps := Computations{
 		T:      i.T,
+		Time:   r.Time,
 		Object: i.Object,
 	}
 	comps.Point = r.Position(comps.T)
 	comps.Eyev = r.Direction.Negative()
-	comps.Normalv = comps.Object.NormalAt(comps.Point)
+	comps.Normalv = NormalAtTime(comps.Object, comps.Point, r.Time)
 	if comps.Normalv.DotProduct(comps.Eyev) < 0 {
 		comps.Inside = true
 		comps.Normalv = comps.Normalv.Negative()
@@ -0,0 +1,76 @@
+package main
+
+import "math"
+
+// NewFisheyeCamera fits a circular fisheye image, fieldOfView radians across,
+// inside the shorter side of the image. Fields of view past π see behind the
+// camera. mapping is "equidistant" or "equisolid".
+func NewFisheyeCamera(h, v int64, fieldOfView float64, mapping string) Camera {
+	bob := NewCamera(h, v, fieldOfView)
+	bob.Projection = "fisheye"
+	bob.FisheyeMap = mapping
+	return bob
+}
+
+// fisheyeDirection is false for samples outside the image circle.
+func (c *Camera) fisheyeDirection(px, py int64, s CameraSample) (Tuple, bool) {
+	radius := math.Min(float64(c.HSize), float64(c.VSize)) / 2
+	nx := (float64(c.HSize)/2 - (float64(px) + s.X)) / radius
+	ny := (float64(c.VSize)/2 - (float64(py) + s.Y)) / radius
+	r := math.Sqrt(nx*nx + ny*ny)
+	if r > 1 {
+		return NewVector(0, 0, -1), false
+	}
+
+	maxTheta := c.FieldOfView / 2
+	var theta float64
+	switch c.FisheyeMap {
+	case "equisolid":
+		theta = 2 * math.Asin(math.Min(1, r*math.Sin(maxTheta/2)))
+	default:
+		theta = r * maxTheta
+	}
+	phi := math.Atan2(ny, nx)
+	return NewVector(
+		math.Sin(theta)*math.Cos(phi),
+		math.Sin(theta)*math.Sin(phi),
+		-math.Cos(theta)), true
+}
+
+// LensDistortion is the Brown–Conrady model: K1-K3 are radial terms and P1,
+// P2 tangential terms, in the same normalised image coordinates as the
+// camera's canvas at z = -1. Calibration tools report them this way.
+type LensDistortion struct {
+	K1, K2, K3 float64
+	P1, P2     float64
+}
+
+func NewLensDistortion(k1, k2, k3, p1, p2 float64) *LensDistortion {
+	return &LensDistortion{K1: k1, K2: k2, K3: k3, P1: p1, P2: p2}
+}
+
+// Distort moves an ideal image point to where the lens would put it.
+func (d *LensDistortion) Distort(x, y float64) (float64, float64) {
+	r2 := x*x + y*y
+	radial := 1 + d.K1*r2 + d.K2*r2*r2 + d.K3*r2*r2*r2
+	dx := 2*d.P1*x*y + d.P2*(r2+2*x*x)
+	dy := d.P1*(r2+2*y*y) + 2*d.P2*x*y
+	return x*radial + dx, y*radial + dy
+}
+
+// Undistort finds the ideal point the lens sends to (x, y). The model has no
+// closed-form inverse so it is solved by fixed-point iteration, which
+// converges quickly for the mild distortions real lenses have.
+func (d *LensDistortion) Undistort(x, y float64) (float64, float64) {
+	ux, uy := x, y
+	for i := 0; i < 20; i++ {
+		dx, dy := d.Distort(ux, uy)
+		ex, ey := dx-x, dy-y
+		ux -= ex
+		uy -= ey
+		if math.Abs(ex) < epsilon*epsilon && math.Abs(ey) < epsilon*epsilon {
+			break
+		}
+	}
+	return ux, uy
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
 	"math"
+	"runtime"
+	"sync"
 )
 
 const epsilon = 0.0001
@@ -12,6 +18,35 @@ func epsilonEquals(a, b float64) bool {
 }
 
 func main() {
+	worker := flag.String("worker", "", "trace tiles for the render coordinator at this address instead of rendering")
+	in := flag.String("in", "", "post-process this image instead of rendering")
+	out := flag.String("out", "", "save the post-processed image here, in the format its extension names")
+	post := flag.String("post", "", `effects to apply to the -in image or the render before saving, such as "bloom:1,2,0.5;vignette:0.3"`)
+	flag.Float64Var(&chapterOptions.Display.Exposure, "exposure", 0, "brighten or darken saved images by this many stops")
+	flag.BoolVar(&chapterOptions.Display.AutoExposure, "auto-exposure", false, "scale saved images so their log average luminance is middle grey")
+	flag.StringVar(&chapterOptions.Display.ToneMap, "tonemap", "none", fmt.Sprintf("tone map for saved images, one of %v", ToneMaps))
+	linear := flag.Bool("linear", false, "write PPM, PNG and JPEG files without the sRGB curve")
+	flag.Parse()
+	if !validToneMap(chapterOptions.Display.ToneMap) {
+		log.Fatalf("-tonemap %q is not one of %v", chapterOptions.Display.ToneMap, ToneMaps)
+	}
+	chapterOptions.Display.SRGB = !*linear
+	effects, err := ParsePostProcess(*post)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chapterOptions.PostProcess = effects
+	if *worker != "" {
+		runWorkers(*worker)
+		return
+	}
+	if *in != "" {
+		if err := postProcessFile(*in, *out); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	//ChapterOne()
 	//ChapterTwo()
 	//ChapterThree()
@@ -27,3 +62,52 @@ func main() {
 	//ChapterFourteen()
 	ChapterFifteen()
 }
+
+// chapterOptions is how the chapters and -in save their images, with the
+// effects and display transform the command line asks for.
+var chapterOptions = NewEncodeOptions()
+
+// saveChapter saves a chapter's image, stopping the program if it cannot.
+func saveChapter(c *Canvas, path string) {
+	if err := c.SaveWith(path, chapterOptions); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func validToneMap(name string) bool {
+	for _, t := range ToneMaps {
+		if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+// runWorkers opens a connection to the coordinator per CPU, each tracing one
+// tile at a time.
+func runWorkers(addr string) {
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := DialWorker(context.Background(), addr); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// postProcessFile loads the image at in and saves it to out with
+// chapterOptions, which carry the -post effects.
+func postProcessFile(in, out string) error {
+	if out == "" {
+		return fmt.Errorf("-in needs an -out file")
+	}
+	c, err := LoadCanvas(in)
+	if err != nil {
+		return err
+	}
+	return c.SaveWith(out, chapterOptions)
+}
@@ -0,0 +1,238 @@
+package main
+
+import "math"
+
+// Motion gives a shape a second transform, reached at time 1. In between, the
+// shape's transform is interpolated, and rays are tested against the shape
+// where it is at the ray's Time.
+type Motion struct {
+	EndTransform Matrix
+	Moving       bool
+}
+
+type Mover interface {
+	SetEndTransform(t Matrix)
+	GetEndTransform() Matrix
+	IsMoving() bool
+}
+
+func (m *Motion) SetEndTransform(t Matrix) {
+	m.EndTransform = t
+	m.Moving = true
+}
+
+func (m *Motion) GetEndTransform() Matrix {
+	return m.EndTransform
+}
+
+func (m *Motion) IsMoving() bool {
+	return m.Moving
+}
+
+// TransformAt is the shape's transform at the given time; static shapes
+// always return GetTransform().
+func TransformAt(s Shaper, time float64) Matrix {
+	if m, ok := s.(Mover); ok && m.IsMoving() {
+		return InterpolateTransform(s.GetTransform(), m.GetEndTransform(), time)
+	}
+	return s.GetTransform()
+}
+
+// motionSteps is how many equal steps of time motionSamples splits the
+// shutter into.
+const motionSteps = 16
+
+// motionSamples are the transforms a shape passes through between time 0 and
+// 1. Bounding them, padded by motionPadding, bounds the whole sweep.
+func motionSamples(s Shaper) []Matrix {
+	m, ok := s.(Mover)
+	if !ok || !m.IsMoving() {
+		return []Matrix{s.GetTransform()}
+	}
+	samples := []Matrix{}
+	for i := 0; i <= motionSteps; i++ {
+		samples = append(samples, TransformAt(s, float64(i)/float64(motionSteps)))
+	}
+	return samples
+}
+
+// motionPadding is how far any corner of box, in the shape's object space,
+// can stray from the box around where motionSamples put them. Translation
+// and scale move points in straight lines, but a turn carries them along
+// arcs that bow out between samples. A path strays from the chord over a
+// step h by at most h²/8 times its largest acceleration, and with the
+// rotation turning through θ at a steady rate that is at most θ²|Sp| plus
+// 2θ|S'p|, where S' is how fast the scale changes.
+func motionPadding(s Shaper, box BoundingCube) float64 {
+	m, ok := s.(Mover)
+	if !ok || !m.IsMoving() {
+		return 0
+	}
+	a := decomposeTransform(s.GetTransform())
+	b := decomposeTransform(m.GetEndTransform())
+	dot := math.Abs(a.Rotation.W*b.Rotation.W + a.Rotation.X*b.Rotation.X + a.Rotation.Y*b.Rotation.Y + a.Rotation.Z*b.Rotation.Z)
+	theta := 2 * math.Acos(math.Min(dot, 1))
+	if theta == 0 {
+		return 0
+	}
+	h := 1.0 / motionSteps
+	pad := 0.0
+	for _, p := range box {
+		var radius, rate float64
+		for i, v := range []float64{p.X, p.Y, p.Z} {
+			scale := math.Max(math.Abs(a.Scale[i]), math.Abs(b.Scale[i]))
+			radius += scale * scale * v * v
+			d := (b.Scale[i] - a.Scale[i]) * v
+			rate += d * d
+		}
+		bow := h * h / 8 * (theta*theta*math.Sqrt(radius) + 2*theta*math.Sqrt(rate))
+		pad = math.Max(pad, bow)
+	}
+	return pad
+}
+
+func WorldToObjectAt(s Shaper, p Tuple, time float64) Tuple {
+	if s.GetParent() != nil {
+		p = WorldToObjectAt(s.GetParent(), p, time)
+	}
+	b := TransformAt(s, time)
+	c := b.Inverse()
+	return c.MultiplyTuple(p)
+}
+
+func NormalToWorldAt(s Shaper, p Tuple, time float64) Tuple {
+	b := TransformAt(s, time)
+	c := b.Inverse()
+	d := c.Transpose()
+	p = d.MultiplyTuple(p)
+	p.W = 0
+	p = p.Normalize()
+	if s.GetParent() != nil {
+		p = NormalToWorldAt(s.GetParent(), p, time)
+	}
+	return p
+}
+
+// NormalAtTime is NormalAt for a shape that may be moving.
+func NormalAtTime(s Shaper, p Tuple, time float64) Tuple {
+	localPoint := WorldToObjectAt(s, p, time)
+	localNormal := s.LocalNormalAt(localPoint)
+	return NormalToWorldAt(s, localNormal, time)
+}
+
+// InterpolateTransform blends two affine transforms by splitting each into
+// translation, rotation and scale, so a spinning object keeps its shape part
+// way through the turn. Sheared transforms only blend approximately.
+func InterpolateTransform(a, b Matrix, t float64) Matrix {
+	if t <= 0 {
+		return a
+	}
+	if t >= 1 {
+		return b
+	}
+	da := decomposeTransform(a)
+	db := decomposeTransform(b)
+	blend := decomposedTransform{Rotation: slerp(da.Rotation, db.Rotation, t)}
+	for i := 0; i < 3; i++ {
+		blend.Translation[i] = da.Translation[i] + (db.Translation[i]-da.Translation[i])*t
+		blend.Scale[i] = da.Scale[i] + (db.Scale[i]-da.Scale[i])*t
+	}
+	return blend.compose()
+}
+
+type quaternion struct {
+	W, X, Y, Z float64
+}
+
+type decomposedTransform struct {
+	Translation [3]float64
+	Rotation    quaternion
+	Scale       [3]float64
+}
+
+func decomposeTransform(m Matrix) decomposedTransform {
+	d := decomposedTransform{}
+	var r [3][3]float64
+	for i := 0; i < 3; i++ {
+		d.Translation[i] = m.Cells[i][3]
+	}
+	for j := 0; j < 3; j++ {
+		d.Scale[j] = math.Sqrt(m.Cells[0][j]*m.Cells[0][j] + m.Cells[1][j]*m.Cells[1][j] + m.Cells[2][j]*m.Cells[2][j])
+	}
+	det := m.Cells[0][0]*(m.Cells[1][1]*m.Cells[2][2]-m.Cells[1][2]*m.Cells[2][1]) -
+		m.Cells[0][1]*(m.Cells[1][0]*m.Cells[2][2]-m.Cells[1][2]*m.Cells[2][0]) +
+		m.Cells[0][2]*(m.Cells[1][0]*m.Cells[2][1]-m.Cells[1][1]*m.Cells[2][0])
+	if det < 0 {
+		d.Scale[0] = -d.Scale[0]
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m.Cells[i][j] / d.Scale[j]
+		}
+	}
+	d.Rotation = quaternionFromRotation(r)
+	return d
+}
+
+func (d decomposedTransform) compose() Matrix {
+	r := d.Rotation.toRotation()
+	m := IdentityMatrix()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m.Cells[i][j] = r[i][j] * d.Scale[j]
+		}
+		m.Cells[i][3] = d.Translation[i]
+	}
+	return m
+}
+
+func quaternionFromRotation(r [3][3]float64) quaternion {
+	trace := r[0][0] + r[1][1] + r[2][2]
+	switch {
+	case trace > 0:
+		s := math.Sqrt(trace+1) * 2
+		return quaternion{s / 4, (r[2][1] - r[1][2]) / s, (r[0][2] - r[2][0]) / s, (r[1][0] - r[0][1]) / s}
+	case r[0][0] > r[1][1] && r[0][0] > r[2][2]:
+		s := math.Sqrt(1+r[0][0]-r[1][1]-r[2][2]) * 2
+		return quaternion{(r[2][1] - r[1][2]) / s, s / 4, (r[0][1] + r[1][0]) / s, (r[0][2] + r[2][0]) / s}
+	case r[1][1] > r[2][2]:
+		s := math.Sqrt(1+r[1][1]-r[0][0]-r[2][2]) * 2
+		return quaternion{(r[0][2] - r[2][0]) / s, (r[0][1] + r[1][0]) / s, s / 4, (r[1][2] + r[2][1]) / s}
+	default:
+		s := math.Sqrt(1+r[2][2]-r[0][0]-r[1][1]) * 2
+		return quaternion{(r[1][0] - r[0][1]) / s, (r[0][2] + r[2][0]) / s, (r[1][2] + r[2][1]) / s, s / 4}
+	}
+}
+
+func (q quaternion) toRotation() [3][3]float64 {
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return [3][3]float64{
+		{1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w)},
+		{2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w)},
+		{2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y)},
+	}
+}
+
+func slerp(a, b quaternion, t float64) quaternion {
+	dot := a.W*b.W + a.X*b.X + a.Y*b.Y + a.Z*b.Z
+	if dot < 0 {
+		b = quaternion{-b.W, -b.X, -b.Y, -b.Z}
+		dot = -dot
+	}
+	var wa, wb float64
+	if dot > 0.9995 {
+		wa, wb = 1-t, t
+	} else {
+		theta := math.Acos(dot)
+		wa = math.Sin((1-t)*theta) / math.Sin(theta)
+		wb = math.Sin(t*theta) / math.Sin(theta)
+	}
+	q := quaternion{
+		a.W*wa + b.W*wb,
+		a.X*wa + b.X*wb,
+		a.Y*wa + b.Y*wb,
+		a.Z*wa + b.Z*wb,
+	}
+	length := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	return quaternion{q.W / length, q.X / length, q.Y / length, q.Z / length}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "math"
+
+// NewPanoramicCamera covers the full sphere of directions around the camera
+// with an equirectangular (longitude/latitude) mapping. The image is usually
+// twice as wide as it is high.
+func NewPanoramicCamera(h, v int64) Camera {
+	bob := NewCamera(h, v, 0)
+	bob.Projection = "equirectangular"
+	return bob
+}
+
+// equirectangularDirection turns a position on the image into a camera space
+// direction. The centre of the image looks down -z like the perspective
+// camera, the left and right edges meet behind the camera.
+func (c *Camera) equirectangularDirection(px, py int64, s CameraSample) Tuple {
+	u := (float64(px) + s.X) / float64(c.HSize)
+	v := (float64(py) + s.Y) / float64(c.VSize)
+	longitude := (0.5 - u) * 2 * math.Pi
+	latitude := (0.5 - v) * math.Pi
+	return NewVector(
+		math.Sin(longitude)*math.Cos(latitude),
+		math.Sin(latitude),
+		-math.Cos(longitude)*math.Cos(latitude))
+}
+
+var CubeMapFaces = []string{"left", "right", "up", "down", "front", "back"}
+
+// cubeFaceRotation turns the camera so that the named face is straight ahead.
+func cubeFaceRotation(face string) Matrix {
+	switch face {
+	case "left":
+		return NewRotationY(math.Pi / 2)
+	case "right":
+		return NewRotationY(-math.Pi / 2)
+	case "up":
+		return NewRotationX(-math.Pi / 2)
+	case "down":
+		return NewRotationX(math.Pi / 2)
+	case "back":
+		return NewRotationY(math.Pi)
+	}
+	return IdentityMatrix()
+}
+
+// RenderCubeMap renders six square 90° faces, size pixels across, looking
+// out from the camera along its own axes.
+func (c *Camera) RenderCubeMap(w World, size int64) map[string]Canvas {
+	faces := map[string]Canvas{}
+	for _, face := range CubeMapFaces {
+		faceCamera := *c
+		faceCamera.HSize = size
+		faceCamera.VSize = size
+		faceCamera.Projection = "perspective"
+		faceCamera.FieldOfView = math.Pi / 2
+		rotation := cubeFaceRotation(face)
+		faceCamera.Transform = rotation.MultiplyMatrix(c.Transform)
+		faceCamera.CalcPixelSize()
+		faces[face] = faceCamera.Render(w)
+	}
+	return faces
+}
+
+// CubeMapCross lays the faces out as a horizontal cross, four faces wide and
+// three high, which most panorama viewers accept directly. If any face has
+// alpha the cross does too, and is transparent around the faces.
+func CubeMapCross(faces map[string]Canvas) Canvas {
+	size := faces["front"].Width
+	cross := NewCanvas(size*4, size*3)
+	for _, f := range faces {
+		if f.HasAlpha() {
+			cross.Alpha = make([]float64, len(cross.Pixels))
+			break
+		}
+	}
+	places := map[string][2]int{
+		"up":    {1, 0},
+		"left":  {0, 1},
+		"front": {1, 1},
+		"right": {2, 1},
+		"back":  {3, 1},
+		"down":  {1, 2},
+	}
+	for face, place := range places {
+		cross.SetRegion(place[0]*size, place[1]*size, faces[face])
+	}
+	return cross
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// WritePFM writes the canvas as a colour Portable FloatMap: 32 bit floats,
+// little endian, with the bottom row first. Nothing is clamped.
+func (c *Canvas) WritePFM(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "PF\n%d %d\n-1.0\n", c.Width, c.Height)
+	buf := make([]byte, 12)
+	for y := c.Height - 1; y >= 0; y-- {
+		for _, col := range c.Row(y) {
+			binary.LittleEndian.PutUint32(buf[0:], math.Float32bits(float32(col.Red)))
+			binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(float32(col.Green)))
+			binary.LittleEndian.PutUint32(buf[8:], math.Float32bits(float32(col.Blue)))
+			out.Write(buf)
+		}
+	}
+	return out.Flush()
+}
+
+// ReadPFM decodes a colour (PF) or greyscale (Pf) Portable FloatMap. The
+// sign of the scale picks the byte order; its size is ignored.
+func ReadPFM(r io.Reader) (Canvas, error) {
+	p := &ppmReader{r: bufio.NewReader(r), format: "pfm", line: 1}
+	magic, line, err := p.token("magic number")
+	if err != nil {
+		return Canvas{}, err
+	}
+	channels := 3
+	switch magic {
+	case "PF":
+	case "Pf":
+		channels = 1
+	default:
+		return Canvas{}, p.failOn(line, "magic number %q is not PF or Pf", magic)
+	}
+	width, err := p.number("width", 0, 1<<24)
+	if err != nil {
+		return Canvas{}, err
+	}
+	height, err := p.number("height", 0, 1<<24)
+	if err != nil {
+		return Canvas{}, err
+	}
+	if width*height > MaxImagePixels {
+		return Canvas{}, p.failOn(p.last, "%dx%d is more than %d pixels", width, height, MaxImagePixels)
+	}
+	tok, line, err := p.token("scale")
+	if err != nil {
+		return Canvas{}, err
+	}
+	scale, err := strconv.ParseFloat(tok, 64)
+	if err != nil || scale == 0 {
+		return Canvas{}, p.failOn(line, "scale %q is not a non-zero number", tok)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	// As with P6, the raster grows as it is read rather than being sized
+	// from the header
+	want := width * height * channels * 4
+	raster, err := io.ReadAll(io.LimitReader(p.r, int64(want)))
+	if err != nil {
+		return Canvas{}, err
+	}
+	if len(raster) < want {
+		return Canvas{}, p.fail("image data ends after %d of %d bytes", len(raster), want)
+	}
+	c := NewCanvas(width, height)
+	sample := func(i int) float64 {
+		return float64(math.Float32frombits(order.Uint32(raster[i*4:])))
+	}
+	for y := 0; y < height; y++ {
+		row := c.Row(height - 1 - y)
+		for x := range row {
+			i := (y*width + x) * channels
+			if channels == 1 {
+				v := sample(i)
+				row[x] = NewColor(v, v, v)
+				continue
+			}
+			row[x] = NewColor(sample(i), sample(i+1), sample(i+2))
+		}
+	}
+	return c, nil
+}
+
+// LoadPFM reads a Portable FloatMap file into a canvas.
+func LoadPFM(path string) (Canvas, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Canvas{}, err
+	}
+	defer f.Close()
+	return ReadPFM(f)
+}
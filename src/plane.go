@@ -2,12 +2,11 @@ package main
 
 import (
 	"math"
-	"math/rand"
-	"time"
 )
 
 type Plane struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
@@ -17,9 +16,8 @@ type Plane struct {
 }
 
 func NewPlane() *Plane {
-	rand.Seed(time.Now().UnixNano())
 	return &Plane{
-		ID:        rand.Intn(100000),
+		ID:        nextShapeID(),
 		Transform: BaseTransform,
 		Origin:    BaseOrigin,
 		Material:  BaseMaterial,
@@ -103,3 +101,7 @@ func (s *Plane) GetMaterial() Material {
 func (s *Plane) SetMaterial(m Material) {
 	s.Material = m
 }
+
+func (s *Plane) GetParent() *Group {
+	return s.Parent
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Effect is one step of a post-process stack. It works on the linear light a
+// camera records, before any display transform, and returns a new canvas.
+// Colours with alpha are premultiplied, so effects moving light around move
+// alpha the same way, and a canvas with alpha keeps it.
+type Effect interface {
+	GetEffectType() string
+	Apply(c *Canvas) Canvas
+}
+
+// PostProcess runs its effects in order.
+type PostProcess []Effect
+
+var EffectTypes = []string{"convolve", "blur", "sharpen", "bloom", "vignette", "chromatic"}
+
+func (p PostProcess) Apply(c *Canvas) Canvas {
+	out := newCanvasLike(c)
+	copy(out.Pixels, c.Pixels)
+	copy(out.Alpha, c.Alpha)
+	for _, e := range p {
+		out = e.Apply(&out)
+	}
+	return out
+}
+
+// newCanvasLike is a black canvas the size of c, with alpha if c has it.
+func newCanvasLike(c *Canvas) Canvas {
+	out := NewCanvas(c.Width, c.Height)
+	if c.HasAlpha() {
+		out.AddAlpha()
+	}
+	return out
+}
+
+// Kernel is a grid of weights with odd sides, centred on the pixel being
+// filtered.
+type Kernel struct {
+	Width   int
+	Height  int
+	Weights []float64
+}
+
+func NewKernel(w, h int, weights ...float64) (Kernel, error) {
+	if w < 1 || h < 1 || w%2 == 0 || h%2 == 0 {
+		return Kernel{}, fmt.Errorf("kernel must have odd sides, not %dx%d", w, h)
+	}
+	if len(weights) != w*h {
+		return Kernel{}, fmt.Errorf("a %dx%d kernel needs %d weights, not %d", w, h, w*h, len(weights))
+	}
+	return Kernel{Width: w, Height: h, Weights: weights}, nil
+}
+
+// Convolve filters the canvas with the kernel. Pixels past the edges repeat
+// the edge, so a flat canvas stays flat under any kernel summing to 1.
+// Alpha is filtered with the same weights, and clamped to [0, 1] since
+// kernels with negative weights can overshoot.
+func (k Kernel) Convolve(c *Canvas) Canvas {
+	out := newCanvasLike(c)
+	rx, ry := k.Width/2, k.Height/2
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			var r, g, b, a float64
+			for j := 0; j < k.Height; j++ {
+				for i := 0; i < k.Width; i++ {
+					w := k.Weights[j*k.Width+i]
+					if w == 0 {
+						continue
+					}
+					col := c.edgePixel(x+i-rx, y+j-ry)
+					r += w * col.Red
+					g += w * col.Green
+					b += w * col.Blue
+					a += w * c.edgeAlpha(x+i-rx, y+j-ry)
+				}
+			}
+			out.Pixels[y*c.Width+x] = NewColor(r, g, b)
+			out.WriteAlpha(x, y, math.Min(math.Max(a, 0), 1))
+		}
+	}
+	return out
+}
+
+// edgePixel is PixelAt with coordinates outside the canvas moved to the
+// nearest edge.
+func (c *Canvas) edgePixel(x, y int) Color {
+	if c.Width == 0 || c.Height == 0 {
+		return Color{}
+	}
+	x = int(math.Min(math.Max(float64(x), 0), float64(c.Width-1)))
+	y = int(math.Min(math.Max(float64(y), 0), float64(c.Height-1)))
+	return c.Pixels[y*c.Width+x]
+}
+
+// edgeAlpha is AlphaAt with coordinates outside the canvas moved to the
+// nearest edge.
+func (c *Canvas) edgeAlpha(x, y int) float64 {
+	if c.Width == 0 || c.Height == 0 {
+		return 0
+	}
+	x = int(math.Min(math.Max(float64(x), 0), float64(c.Width-1)))
+	y = int(math.Min(math.Max(float64(y), 0), float64(c.Height-1)))
+	return c.AlphaAt(x, y)
+}
+
+// gaussianWeights is a normalised one dimensional Gaussian reaching out
+// three sigmas.
+func gaussianWeights(sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	weights := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range weights {
+		d := float64(i - radius)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return weights
+}
+
+type ConvolveEffect struct {
+	Kernel Kernel
+}
+
+func NewConvolveEffect(k Kernel) *ConvolveEffect {
+	return &ConvolveEffect{Kernel: k}
+}
+
+func (e *ConvolveEffect) GetEffectType() string  { return "convolve" }
+func (e *ConvolveEffect) Apply(c *Canvas) Canvas { return e.Kernel.Convolve(c) }
+
+// BlurEffect is a Gaussian blur, run as a horizontal then a vertical pass.
+type BlurEffect struct {
+	Sigma float64
+}
+
+func NewBlurEffect(sigma float64) *BlurEffect {
+	return &BlurEffect{Sigma: sigma}
+}
+
+func (e *BlurEffect) GetEffectType() string { return "blur" }
+func (e *BlurEffect) Apply(c *Canvas) Canvas {
+	if e.Sigma <= 0 {
+		return PostProcess{}.Apply(c)
+	}
+	weights := gaussianWeights(e.Sigma)
+	across := Kernel{Width: len(weights), Height: 1, Weights: weights}
+	down := Kernel{Width: 1, Height: len(weights), Weights: weights}
+	out := across.Convolve(c)
+	return down.Convolve(&out)
+}
+
+// SharpenEffect subtracts Amount times each of a pixel's four neighbours and
+// adds the same back to the pixel, leaving flat areas alone.
+type SharpenEffect struct {
+	Amount float64
+}
+
+func NewSharpenEffect(amount float64) *SharpenEffect {
+	return &SharpenEffect{Amount: amount}
+}
+
+func (e *SharpenEffect) GetEffectType() string { return "sharpen" }
+func (e *SharpenEffect) Apply(c *Canvas) Canvas {
+	a := e.Amount
+	k := Kernel{Width: 3, Height: 3, Weights: []float64{
+		0, -a, 0,
+		-a, 1 + 4*a, -a,
+		0, -a, 0,
+	}}
+	return k.Convolve(c)
+}
+
+// BloomEffect makes bright highlights glow: whatever light is above
+// Threshold in each channel is blurred by Sigma and added back, scaled by
+// Strength. Where the glow spreads over transparent pixels it covers them
+// by as much as its brightest channel, so it is not lost with straight
+// alpha.
+type BloomEffect struct {
+	Threshold float64
+	Sigma     float64
+	Strength  float64
+}
+
+func NewBloomEffect(threshold, sigma, strength float64) *BloomEffect {
+	return &BloomEffect{Threshold: threshold, Sigma: sigma, Strength: strength}
+}
+
+func (e *BloomEffect) GetEffectType() string { return "bloom" }
+func (e *BloomEffect) Apply(c *Canvas) Canvas {
+	bright := NewCanvas(c.Width, c.Height)
+	for i, col := range c.Pixels {
+		bright.Pixels[i] = NewColor(
+			math.Max(col.Red-e.Threshold, 0),
+			math.Max(col.Green-e.Threshold, 0),
+			math.Max(col.Blue-e.Threshold, 0))
+	}
+	glow := NewBlurEffect(e.Sigma).Apply(&bright)
+	out := newCanvasLike(c)
+	for i, col := range c.Pixels {
+		added := glow.Pixels[i].MultiplyScalar(e.Strength)
+		out.Pixels[i] = col.Add(added)
+		if out.HasAlpha() {
+			cover := math.Max(added.Red, math.Max(added.Green, added.Blue))
+			out.Alpha[i] = math.Min(c.Alpha[i]+cover, 1)
+		}
+	}
+	return out
+}
+
+// VignetteEffect darkens towards the corners. A pixel at distance d from
+// the centre, with the corners at d = 1, is scaled by 1 - Strength * d^2.
+type VignetteEffect struct {
+	Strength float64
+}
+
+func NewVignetteEffect(strength float64) *VignetteEffect {
+	return &VignetteEffect{Strength: strength}
+}
+
+func (e *VignetteEffect) GetEffectType() string { return "vignette" }
+func (e *VignetteEffect) Apply(c *Canvas) Canvas {
+	out := newCanvasLike(c)
+	copy(out.Alpha, c.Alpha)
+	cx, cy := float64(c.Width)/2, float64(c.Height)/2
+	corner := cx*cx + cy*cy
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			scale := math.Max(1-e.Strength*(dx*dx+dy*dy)/corner, 0)
+			out.Pixels[y*c.Width+x] = c.Pixels[y*c.Width+x].MultiplyScalar(scale)
+		}
+	}
+	return out
+}
+
+// ChromaticAberrationEffect splits colours the way a cheap lens does: red
+// is magnified by 1 + Shift about the centre and blue shrunk by 1 - Shift,
+// so fringes grow towards the edges. Green stays put. Shift must lie
+// strictly between -1 and 1, or a channel would be shrunk to nothing or
+// turned inside out. A pixel's alpha is the largest of the alphas its three
+// channels were taken from, so fringes over a transparent background show.
+type ChromaticAberrationEffect struct {
+	Shift float64
+}
+
+func NewChromaticAberrationEffect(shift float64) (*ChromaticAberrationEffect, error) {
+	if !(shift > -1 && shift < 1) {
+		return nil, fmt.Errorf("chromatic shift %g is outside -1 to 1", shift)
+	}
+	return &ChromaticAberrationEffect{Shift: shift}, nil
+}
+
+func (e *ChromaticAberrationEffect) GetEffectType() string { return "chromatic" }
+func (e *ChromaticAberrationEffect) Apply(c *Canvas) Canvas {
+	out := newCanvasLike(c)
+	cx, cy := float64(c.Width)/2, float64(c.Height)/2
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			// A channel magnified by m shows what was 1/m as far out
+			red, redAlpha := c.bilinear(cx+dx/(1+e.Shift), cy+dy/(1+e.Shift))
+			blue, blueAlpha := c.bilinear(cx+dx/(1-e.Shift), cy+dy/(1-e.Shift))
+			green := c.Pixels[y*c.Width+x]
+			out.Pixels[y*c.Width+x] = NewColor(red.Red, green.Green, blue.Blue)
+			out.WriteAlpha(x, y, math.Max(c.AlphaAt(x, y), math.Max(redAlpha, blueAlpha)))
+		}
+	}
+	return out
+}
+
+// bilinear samples the canvas and its alpha between pixel centres, with the
+// edges repeated past the border.
+func (c *Canvas) bilinear(fx, fy float64) (Color, float64) {
+	fx, fy = fx-0.5, fy-0.5
+	x0, y0 := math.Floor(fx), math.Floor(fy)
+	tx, ty := fx-x0, fy-y0
+	x, y := int(x0), int(y0)
+	top := c.edgePixel(x, y).MultiplyScalar(1 - tx).Add(c.edgePixel(x+1, y).MultiplyScalar(tx))
+	bottom := c.edgePixel(x, y+1).MultiplyScalar(1 - tx).Add(c.edgePixel(x+1, y+1).MultiplyScalar(tx))
+	topAlpha := c.edgeAlpha(x, y)*(1-tx) + c.edgeAlpha(x+1, y)*tx
+	bottomAlpha := c.edgeAlpha(x, y+1)*(1-tx) + c.edgeAlpha(x+1, y+1)*tx
+	return top.MultiplyScalar(1 - ty).Add(bottom.MultiplyScalar(ty)), topAlpha*(1-ty) + bottomAlpha*ty
+}
+
+// ParsePostProcess reads a stack from the command line: effects separated
+// by semicolons, each a name from EffectTypes and its numbers after a colon.
+//
+//	blur:sigma
+//	sharpen:amount
+//	bloom:threshold,sigma,strength
+//	vignette:strength
+//	chromatic:shift
+//	convolve:width,height,weight,...
+//
+// For example "bloom:1,2,0.5;vignette:0.3".
+func ParsePostProcess(spec string) (PostProcess, error) {
+	p := PostProcess{}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, args, _ := strings.Cut(part, ":")
+		var values []float64
+		if strings.TrimSpace(args) != "" {
+			for _, a := range strings.Split(args, ",") {
+				v, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %q is not a number", name, a)
+				}
+				values = append(values, v)
+			}
+		}
+		e, err := newEffect(strings.TrimSpace(name), values)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, e)
+	}
+	return p, nil
+}
+
+func newEffect(name string, v []float64) (Effect, error) {
+	want := map[string]int{"blur": 1, "sharpen": 1, "bloom": 3, "vignette": 1, "chromatic": 1}
+	if n, ok := want[name]; ok && len(v) != n {
+		return nil, fmt.Errorf("%s takes %d numbers, not %d", name, n, len(v))
+	}
+	switch name {
+	case "blur":
+		return NewBlurEffect(v[0]), nil
+	case "sharpen":
+		return NewSharpenEffect(v[0]), nil
+	case "bloom":
+		return NewBloomEffect(v[0], v[1], v[2]), nil
+	case "vignette":
+		return NewVignetteEffect(v[0]), nil
+	case "chromatic":
+		e, err := NewChromaticAberrationEffect(v[0])
+		if err != nil {
+			return nil, err
+		}
+		return e, nil
+	case "convolve":
+		if len(v) < 2 {
+			return nil, fmt.Errorf("convolve needs a width and height")
+		}
+		k, err := NewKernel(int(v[0]), int(v[1]), v[2:]...)
+		if err != nil {
+			return nil, err
+		}
+		return NewConvolveEffect(k), nil
+	}
+	return nil, fmt.Errorf("unknown effect %q", name)
+}
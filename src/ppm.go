@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ImageFileError reports where an image file went wrong, counting lines
+// from 1.
+type ImageFileError struct {
+	Format string
+	Line   int
+	Msg    string
+}
+
+func (e *ImageFileError) Error() string {
+	return fmt.Sprintf("%s line %d: %s", e.Format, e.Line, e.Msg)
+}
+
+// MaxImagePixels caps the width times height the image readers accept, so
+// a header alone cannot make them allocate gigabytes.
+const MaxImagePixels = 1 << 26
+
+// ppmReader splits a PPM or PFM header into whitespace separated tokens,
+// skipping comments, and keeps count of the line it is on.
+type ppmReader struct {
+	r      *bufio.Reader
+	format string
+	line   int
+	// last is the line the last token was on
+	last int
+}
+
+func isPPMSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
+
+func (p *ppmReader) readByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil && b == '\n' {
+		p.line++
+	}
+	return b, err
+}
+
+func (p *ppmReader) fail(format string, args ...interface{}) error {
+	return p.failOn(p.line, format, args...)
+}
+
+func (p *ppmReader) failOn(line int, format string, args ...interface{}) error {
+	return &ImageFileError{Format: p.format, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// token returns the next token and the line it is on, and eats the one
+// whitespace byte after it so a P6 raster starts straight after its max
+// value.
+func (p *ppmReader) token(what string) (string, int, error) {
+	b, err := p.readByte()
+	for err == nil && (isPPMSpace(b) || b == '#') {
+		if b == '#' {
+			for err == nil && b != '\n' {
+				b, err = p.readByte()
+			}
+			continue
+		}
+		b, err = p.readByte()
+	}
+	if err == io.EOF {
+		return "", p.line, p.fail("file ends before the %s", what)
+	}
+	if err != nil {
+		return "", p.line, err
+	}
+	line := p.line
+	p.last = line
+	tok := []byte{b}
+	for {
+		b, err = p.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", line, err
+		}
+		if isPPMSpace(b) {
+			if b == '\n' {
+				p.line++
+			}
+			break
+		}
+		if b == '#' {
+			p.r.UnreadByte()
+			break
+		}
+		tok = append(tok, b)
+	}
+	return string(tok), line, nil
+}
+
+func (p *ppmReader) number(what string, min, max int) (int, error) {
+	tok, line, err := p.token(what)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(tok)
+	if err != nil {
+		return 0, p.failOn(line, "%s %q is not a number", what, tok)
+	}
+	if v < min || v > max {
+		return 0, p.failOn(line, "%s %d is outside %d to %d", what, v, min, max)
+	}
+	return v, nil
+}
+
+// ReadPPM decodes a plain (P3) or binary (P6) PPM. Samples are scaled by
+// the file's max value, and P6 files with a max value above 255 use two
+// bytes a sample, most significant first. Only the first image of a file is
+// read.
+func ReadPPM(r io.Reader) (Canvas, error) {
+	p := &ppmReader{r: bufio.NewReader(r), format: "ppm", line: 1}
+	magic, line, err := p.token("magic number")
+	if err != nil {
+		return Canvas{}, err
+	}
+	if magic != "P3" && magic != "P6" {
+		return Canvas{}, p.failOn(line, "magic number %q is not P3 or P6", magic)
+	}
+	width, err := p.number("width", 0, 1<<24)
+	if err != nil {
+		return Canvas{}, err
+	}
+	height, err := p.number("height", 0, 1<<24)
+	if err != nil {
+		return Canvas{}, err
+	}
+	if width*height > MaxImagePixels {
+		return Canvas{}, p.failOn(p.last, "%dx%d is more than %d pixels", width, height, MaxImagePixels)
+	}
+	maxval, err := p.number("max value", 1, 65535)
+	if err != nil {
+		return Canvas{}, err
+	}
+
+	scale := float64(maxval)
+	samples := make([]float64, 3)
+	if magic == "P3" {
+		// Pixels are appended as their samples arrive, so a header promising
+		// more than the file holds fails before much is allocated
+		pixels := []Color{}
+		for i := 0; i < width*height; i++ {
+			for j := range samples {
+				v, err := p.number("sample", 0, maxval)
+				if err != nil {
+					return Canvas{}, err
+				}
+				samples[j] = float64(v) / scale
+			}
+			pixels = append(pixels, NewColor(samples[0], samples[1], samples[2]))
+		}
+		return Canvas{Width: width, Height: height, Pixels: pixels}, nil
+	}
+
+	size := 1
+	if maxval > 255 {
+		size = 2
+	}
+	// The raster grows as it is read, so a header promising more than the
+	// file holds fails without allocating all of it first
+	want := width * height * 3 * size
+	raster, err := io.ReadAll(io.LimitReader(p.r, int64(want)))
+	if err != nil {
+		return Canvas{}, err
+	}
+	if len(raster) < want {
+		return Canvas{}, p.fail("image data ends after %d of %d bytes", len(raster), want)
+	}
+	c := NewCanvas(width, height)
+	for i := range c.Pixels {
+		for j := range samples {
+			k := (i*3 + j) * size
+			v := int(raster[k])
+			if size == 2 {
+				v = v<<8 | int(raster[k+1])
+			}
+			if v > maxval {
+				return Canvas{}, p.fail("sample %d of pixel %d is %d, above the max value %d", j, i, v, maxval)
+			}
+			samples[j] = float64(v) / scale
+		}
+		c.Pixels[i] = NewColor(samples[0], samples[1], samples[2])
+	}
+	return c, nil
+}
+
+// LoadPPM reads a P3 or P6 file into a canvas.
+func LoadPPM(path string) (Canvas, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Canvas{}, err
+	}
+	defer f.Close()
+	return ReadPPM(f)
+}
+
+// WriteP6 writes the canvas's colours as they are as a binary PPM with a
+// max value of 255.
+func (c *Canvas) WriteP6(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "P6\n%d %d\n255\n", c.Width, c.Height)
+	for _, col := range c.Pixels {
+		out.WriteByte(byte(colorToDepth(col.Red, 255)))
+		out.WriteByte(byte(colorToDepth(col.Green, 255)))
+		out.WriteByte(byte(colorToDepth(col.Blue, 255)))
+	}
+	return out.Flush()
+}
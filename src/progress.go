@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Progress is handed to a render's progress callback each time a tile is
+// finished. Remaining is estimated from the average time per pixel so far.
+type Progress struct {
+	TilesDone   int
+	TilesTotal  int
+	PixelsDone  int64
+	PixelsTotal int64
+	Elapsed     time.Duration
+	Remaining   time.Duration
+}
+
+// Fraction is how much of the render is finished, from 0 to 1.
+func (p Progress) Fraction() float64 {
+	if p.PixelsTotal == 0 {
+		return 1
+	}
+	return float64(p.PixelsDone) / float64(p.PixelsTotal)
+}
+
+// RenderContext renders like Render, calling progress, if given, after every
+// tile. Calls to progress never overlap. If ctx is cancelled the workers
+// stop at the end of the row they are tracing, and the partly filled canvas
+// is returned with ctx's error.
+func (c *Camera) RenderContext(ctx context.Context, w World, progress func(p Progress)) (Canvas, error) {
+	crop := c.CropWindow()
+	image := c.newImage()
+	if c.Stats != nil {
+		c.Stats.Reset()
+	}
+
+	tiles := c.Tiles()
+	p := Progress{TilesTotal: len(tiles), PixelsTotal: crop.Width * crop.Height}
+	start := time.Now()
+	var lock sync.Mutex
+	done := func(t Tile) {
+		if progress == nil {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		p.TilesDone++
+		p.PixelsDone += t.Width * t.Height
+		p.Elapsed = time.Since(start)
+		p.Remaining = time.Duration(float64(p.Elapsed) * float64(p.PixelsTotal-p.PixelsDone) / float64(p.PixelsDone))
+		progress(p)
+	}
+	err := c.traceTilesContext(ctx, w, tiles, image.putAt(crop.X, crop.Y), done)
+	return image, err
+}
@@ -5,6 +5,7 @@ import "log"
 type Ray struct {
 	Origin    Tuple
 	Direction Tuple
+	Time      float64
 }
 
 func NewRay(origin Tuple, direction Tuple) Ray {
@@ -22,5 +23,12 @@ func (r *Ray) Position(t float64) Tuple {
 }
 
 func (r Ray) Transform(m Matrix) Ray {
-	return NewRay(m.MultiplyTuple(r.Origin), m.MultiplyTuple(r.Direction))
+	return NewRayAt(m.MultiplyTuple(r.Origin), m.MultiplyTuple(r.Direction), r.Time)
+}
+
+// NewRayAt is a ray that sees moving shapes where they are at time.
+func NewRayAt(origin Tuple, direction Tuple, time float64) Ray {
+	r := NewRay(origin, direction)
+	r.Time = time
+	return r
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// CameraSample is a position inside a pixel, with X and Y in [0, 1) measured
+// from the pixel's top left corner. LensX and LensY place the ray on the unit
+// lens disk; the zero value is the centre of the lens, i.e. a pinhole. Time
+// runs from 0 when the shutter opens to 1 when it closes.
+type CameraSample struct {
+	X     float64
+	Y     float64
+	LensX float64
+	LensY float64
+	Time  float64
+}
+
+func NewCameraSample(x, y float64) CameraSample {
+	return CameraSample{X: x, Y: y}
+}
+
+var pixelCentre = NewCameraSample(0.5, 0.5)
+
+// sampleRand returns a generator that depends only on the camera seed and the
+// pixel, so a pixel gets the same samples whichever worker traces it.
+func (c *Camera) sampleRand(px, py int64) *rand.Rand {
+	seed := c.Seed
+	seed = seed*6364136223846793005 + px + 1442695040888963407
+	seed = seed*6364136223846793005 + py + 1442695040888963407
+	return rand.New(rand.NewSource(seed))
+}
+
+// PixelSamples lays out Samples×Samples positions in the pixel according to
+// SampleMode: "grid" (regular), "jitter" (one random point per stratum) or
+// "random" (uniform over the whole pixel). The "adaptive" mode picks its own
+// positions while rendering; see renderAdaptiveTile and adaptiveCameraSample.
+func (c *Camera) PixelSamples(px, py int64) []CameraSample {
+	n := c.Samples
+	if n < 1 {
+		n = 1
+	}
+	if n == 1 && c.SampleMode != "random" && c.SampleMode != "jitter" && c.Aperture <= 0 && c.ShutterClose <= c.ShutterOpen {
+		return []CameraSample{pixelCentre}
+	}
+
+	rnd := c.sampleRand(px, py)
+	samples := make([]CameraSample, 0, n*n)
+	step := 1.0 / float64(n)
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			switch c.SampleMode {
+			case "jitter":
+				samples = append(samples, NewCameraSample(
+					(float64(i)+rnd.Float64())*step,
+					(float64(j)+rnd.Float64())*step))
+			case "random":
+				samples = append(samples, NewCameraSample(rnd.Float64(), rnd.Float64()))
+			default:
+				samples = append(samples, NewCameraSample(
+					(float64(i)+0.5)*step,
+					(float64(j)+0.5)*step))
+			}
+		}
+	}
+	if c.Aperture > 0 {
+		for i := range samples {
+			samples[i].LensX, samples[i].LensY = concentricDisk(rnd.Float64(), rnd.Float64())
+		}
+	}
+	if c.ShutterClose > c.ShutterOpen {
+		// One time per stratum of the shutter, shuffled so time is not
+		// correlated with position in the pixel
+		times := rnd.Perm(len(samples))
+		for i := range samples {
+			samples[i].Time = (float64(times[i]) + rnd.Float64()) / float64(len(samples))
+		}
+	}
+	return samples
+}
+
+// concentricDisk maps the unit square onto the unit disk keeping strata
+// roughly square (Shirley and Chiu), so jittered lens samples stay jittered.
+func concentricDisk(u, v float64) (float64, float64) {
+	a := 2*u - 1
+	b := 2*v - 1
+	if a == 0 && b == 0 {
+		return 0, 0
+	}
+	var r, theta float64
+	if math.Abs(a) > math.Abs(b) {
+		r = a
+		theta = math.Pi / 4 * (b / a)
+	} else {
+		r = b
+		theta = math.Pi/2 - math.Pi/4*(a/b)
+	}
+	return r * math.Cos(theta), r * math.Sin(theta)
+}
+
+// PixelColor traces every sample for the pixel and combines them with the
+// camera's filter.
+func (c *Camera) PixelColor(w World, px, py int64) Color {
+	color, _ := c.PixelColorCoverage(w, px, py)
+	return color
+}
+
+// PixelColorCoverage is PixelColor along with the pixel's alpha: the
+// coverage of each sample, from the same rays as its colour, combined with
+// the filter the same way.
+func (c *Camera) PixelColorCoverage(w World, px, py int64) (Color, float64) {
+	samples := c.PixelSamples(px, py)
+	c.Stats.addCameraRays(int64(len(samples)))
+	if len(samples) == 1 {
+		return c.traceSampleCoverage(w, px, py, samples[0])
+	}
+
+	colors := make([]Color, len(samples))
+	coverage := make([]Color, len(samples))
+	for i, s := range samples {
+		col, a := c.traceSampleCoverage(w, px, py, s)
+		colors[i] = col
+		coverage[i] = NewColor(a, a, a)
+	}
+	a := c.filterSamples(samples, coverage).Red
+	// A Mitchell filter narrower than a pixel gives the outer samples
+	// negative weights, which can push it a little out of range
+	return c.filterSamples(samples, colors), math.Min(math.Max(a, 0), 1)
+}
+
+// traceSampleCoverage traces one sample, giving its colour and coverage.
+// Both are 0 wherever the projection has no ray for the sample.
+func (c *Camera) traceSampleCoverage(w World, px, py int64, s CameraSample) (Color, float64) {
+	if !c.InView(px, py, s) {
+		return NewColor(0, 0, 0), 0
+	}
+	return w.ColorCoverageAt(c.RayForSample(px, py, s), maxReflects)
+}
+
+func (c *Camera) filterSamples(samples []CameraSample, colors []Color) Color {
+	filter := c.Filter
+	if filter == nil {
+		filter = NewBoxFilter()
+	}
+	sum := NewColor(0, 0, 0)
+	total := 0.0
+	for i, s := range samples {
+		weight := filter.Weight(s.X-0.5, s.Y-0.5)
+		sum = sum.Add(colors[i].MultiplyScalar(weight))
+		total += weight
+	}
+	if math.Abs(total) < epsilon {
+		// Every sample sat on the filter's zero crossing; fall back to a plain mean
+		sum = NewColor(0, 0, 0)
+		for _, col := range colors {
+			sum = sum.Add(col)
+		}
+		total = float64(len(colors))
+	}
+	return sum.MultiplyScalar(1 / total)
+}
+
+// Filter weights a sample by its offset, in pixels, from the pixel centre.
+// A pixel only weights its own samples, none of its neighbours', so a filter
+// leans the average towards the centre of the pixel rather than
+// reconstructing the image across pixels. Samples are never more than half
+// a pixel from the centre, so any support beyond that goes unused: a
+// Mitchell filter of radius 1 or more never reaches its negative lobes.
+type Filter interface {
+	GetFilterType() string
+	GetRadius() float64
+	Weight(dx, dy float64) float64
+}
+
+type BoxFilter struct {
+	Radius float64
+}
+
+func NewBoxFilter() *BoxFilter {
+	return &BoxFilter{Radius: 0.5}
+}
+
+func (f *BoxFilter) GetFilterType() string { return "box" }
+func (f *BoxFilter) GetRadius() float64    { return f.Radius }
+func (f *BoxFilter) Weight(dx, dy float64) float64 {
+	if math.Abs(dx) > f.Radius || math.Abs(dy) > f.Radius {
+		return 0
+	}
+	return 1
+}
+
+type TentFilter struct {
+	Radius float64
+}
+
+func NewTentFilter(radius float64) *TentFilter {
+	return &TentFilter{Radius: radius}
+}
+
+func (f *TentFilter) GetFilterType() string { return "tent" }
+func (f *TentFilter) GetRadius() float64    { return f.Radius }
+func (f *TentFilter) Weight(dx, dy float64) float64 {
+	return math.Max(0, f.Radius-math.Abs(dx)) * math.Max(0, f.Radius-math.Abs(dy))
+}
+
+type GaussianFilter struct {
+	Radius float64
+	Alpha  float64
+}
+
+func NewGaussianFilter(radius, alpha float64) *GaussianFilter {
+	return &GaussianFilter{Radius: radius, Alpha: alpha}
+}
+
+func (f *GaussianFilter) GetFilterType() string { return "gaussian" }
+func (f *GaussianFilter) GetRadius() float64    { return f.Radius }
+func (f *GaussianFilter) Weight(dx, dy float64) float64 {
+	return f.gaussian(dx) * f.gaussian(dy)
+}
+
+// gaussian is shifted down so it reaches zero at the radius rather than
+// being cut off abruptly.
+func (f *GaussianFilter) gaussian(d float64) float64 {
+	edge := math.Exp(-f.Alpha * f.Radius * f.Radius)
+	return math.Max(0, math.Exp(-f.Alpha*d*d)-edge)
+}
+
+type MitchellFilter struct {
+	Radius float64
+	B      float64
+	C      float64
+}
+
+// NewMitchellFilter with b = c = 1/3 gives the filter Mitchell and Netravali
+// recommend.
+func NewMitchellFilter(radius, b, c float64) *MitchellFilter {
+	return &MitchellFilter{Radius: radius, B: b, C: c}
+}
+
+func (f *MitchellFilter) GetFilterType() string { return "mitchell" }
+func (f *MitchellFilter) GetRadius() float64    { return f.Radius }
+func (f *MitchellFilter) Weight(dx, dy float64) float64 {
+	return f.mitchell(dx/f.Radius) * f.mitchell(dy/f.Radius)
+}
+
+// mitchell evaluates the cubic over [-1, 1], stretched from its natural
+// support of [-2, 2].
+func (f *MitchellFilter) mitchell(x float64) float64 {
+	x = math.Abs(2 * x)
+	b, c := f.B, f.C
+	if x > 2 {
+		return 0
+	}
+	if x > 1 {
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	}
+	return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+}
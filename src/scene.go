@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// SceneDescription is a World and Camera reduced to plain data, so it can be
+// written to a file or sent to another process and rebuilt there.
+type SceneDescription struct {
+	Camera  CameraDescription
+	Lights  []Light
+	Objects []ShapeDescription
+}
+
+type CameraDescription struct {
+	HSize       int64
+	VSize       int64
+	Projection  string
+	FieldOfView float64
+	ViewWidth   float64
+	ViewHeight  float64
+	Transform   [4][4]float64
+	Samples     int
+	SampleMode  string
+	Filter      FilterDescription
+	Seed        int64
+
+	Aperture      float64
+	FocalDistance float64
+	ShiftX        float64
+	ShiftY        float64
+	Distortion    *LensDistortion
+	FisheyeMap    string
+	ShutterOpen   float64
+	ShutterClose  float64
+
+	AdaptiveThreshold float64
+	AdaptiveDepth     int
+
+	Alpha bool
+}
+
+type FilterDescription struct {
+	Type   string
+	Radius float64
+	Alpha  float64
+	B      float64
+	C      float64
+}
+
+type ShapeDescription struct {
+	Type         string
+	Transform    [4][4]float64
+	Moving       bool
+	EndTransform [4][4]float64
+	Material     MaterialDescription
+	Minimum      float64
+	Maximum      float64
+	Closed       bool
+	Points       [3]Tuple
+	Children     []ShapeDescription
+}
+
+type MaterialDescription struct {
+	Color           Color
+	Ambient         float64
+	Diffuse         float64
+	Specular        float64
+	Shininess       float64
+	Reflective      float64
+	Transparency    float64
+	RefractiveIndex float64
+	Pattern         *PatternDescription
+}
+
+type PatternDescription struct {
+	Type      string
+	A, B      Color
+	Transform [4][4]float64
+}
+
+func describeMatrix(m Matrix) [4][4]float64 {
+	d := [4][4]float64{}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			d[i][j] = m.Cells[i][j]
+		}
+	}
+	return d
+}
+
+func buildMatrix(d [4][4]float64) Matrix {
+	m := IdentityMatrix()
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			m.Cells[i][j] = d[i][j]
+		}
+	}
+	return m
+}
+
+// DescribeScene captures a world and camera. Every shape and pattern the
+// renderer builds is supported; test shapes and test patterns are not.
+func DescribeScene(w World, c Camera) (SceneDescription, error) {
+	d := SceneDescription{
+		Camera: CameraDescription{
+			HSize:             c.HSize,
+			VSize:             c.VSize,
+			Projection:        c.Projection,
+			FieldOfView:       c.FieldOfView,
+			ViewWidth:         c.ViewWidth,
+			ViewHeight:        c.ViewHeight,
+			Transform:         describeMatrix(c.Transform),
+			Samples:           c.Samples,
+			SampleMode:        c.SampleMode,
+			Seed:              c.Seed,
+			Aperture:          c.Aperture,
+			FocalDistance:     c.FocalDistance,
+			ShiftX:            c.ShiftX,
+			ShiftY:            c.ShiftY,
+			Distortion:        c.Distortion,
+			FisheyeMap:        c.FisheyeMap,
+			ShutterOpen:       c.ShutterOpen,
+			ShutterClose:      c.ShutterClose,
+			AdaptiveThreshold: c.AdaptiveThreshold,
+			AdaptiveDepth:     c.AdaptiveDepth,
+			Alpha:             c.Alpha,
+		},
+		Lights: w.Lights,
+	}
+	switch f := c.Filter.(type) {
+	case nil:
+	case *BoxFilter:
+		d.Camera.Filter = FilterDescription{Type: "box", Radius: f.Radius}
+	case *TentFilter:
+		d.Camera.Filter = FilterDescription{Type: "tent", Radius: f.Radius}
+	case *GaussianFilter:
+		d.Camera.Filter = FilterDescription{Type: "gaussian", Radius: f.Radius, Alpha: f.Alpha}
+	case *MitchellFilter:
+		d.Camera.Filter = FilterDescription{Type: "mitchell", Radius: f.Radius, B: f.B, C: f.C}
+	default:
+		return d, fmt.Errorf("cannot describe a %s filter", c.Filter.GetFilterType())
+	}
+	for _, o := range w.Objects {
+		s, err := describeShape(o)
+		if err != nil {
+			return d, err
+		}
+		d.Objects = append(d.Objects, s)
+	}
+	return d, nil
+}
+
+func describeShape(s Shaper) (ShapeDescription, error) {
+	d := ShapeDescription{Transform: describeMatrix(s.GetTransform())}
+	if m, ok := s.(Mover); ok && m.IsMoving() {
+		d.Moving = true
+		d.EndTransform = describeMatrix(m.GetEndTransform())
+	}
+	switch t := s.(type) {
+	case *Sphere:
+		d.Type = "sphere"
+	case *Plane:
+		d.Type = "plane"
+	case *Cube:
+		d.Type = "cube"
+	case *Cylinder:
+		d.Type = "cylinder"
+		d.Minimum, d.Maximum, d.Closed = t.Minimum, t.Maximum, t.Closed
+	case *Cone:
+		d.Type = "cone"
+		d.Minimum, d.Maximum, d.Closed = t.Minimum, t.Maximum, t.Closed
+	case *Triangle:
+		d.Type = "triangle"
+		d.Points = [3]Tuple{t.P1, t.P2, t.P3}
+	case *Group:
+		d.Type = "group"
+		for _, child := range t.GetShapes() {
+			c, err := describeShape(child)
+			if err != nil {
+				return d, err
+			}
+			d.Children = append(d.Children, c)
+		}
+		// Groups have no material of their own
+		return d, nil
+	default:
+		return d, fmt.Errorf("cannot describe a %s shape", s.GetType())
+	}
+	m, err := describeMaterial(s.GetMaterial())
+	d.Material = m
+	return d, err
+}
+
+func describeMaterial(m Material) (MaterialDescription, error) {
+	d := MaterialDescription{
+		Color:           m.Color,
+		Ambient:         m.Ambient,
+		Diffuse:         m.Diffuse,
+		Specular:        m.Specular,
+		Shininess:       m.Shininess,
+		Reflective:      m.Reflective,
+		Transparency:    m.Transparency,
+		RefractiveIndex: m.RefractiveIndex,
+	}
+	if !m.HasPattern {
+		return d, nil
+	}
+	p := &PatternDescription{Type: m.Pattern.GetPatternType(), Transform: describeMatrix(m.Pattern.GetTransform())}
+	switch t := m.Pattern.(type) {
+	case *StripePattern:
+		p.A, p.B = t.A, t.B
+	case *GradientPattern:
+		p.A, p.B = t.A, t.B
+	case *RingPattern:
+		p.A, p.B = t.A, t.B
+	case *CheckerPattern:
+		p.A, p.B = t.A, t.B
+	default:
+		return d, fmt.Errorf("cannot describe a %s pattern", p.Type)
+	}
+	d.Pattern = p
+	return d, nil
+}
+
+// Build recreates the world and camera the description was taken from.
+func (d SceneDescription) Build() (World, Camera, error) {
+	w := NewWorld()
+	w.Lights = append(w.Lights, d.Lights...)
+
+	dc := d.Camera
+	c := NewCamera(dc.HSize, dc.VSize, dc.FieldOfView)
+	c.Projection = dc.Projection
+	c.ViewWidth = dc.ViewWidth
+	c.ViewHeight = dc.ViewHeight
+	c.CalcPixelSize()
+	c.SetTransform(buildMatrix(dc.Transform))
+	c.Samples = dc.Samples
+	c.SampleMode = dc.SampleMode
+	c.Seed = dc.Seed
+	c.Aperture = dc.Aperture
+	c.FocalDistance = dc.FocalDistance
+	c.ShiftX = dc.ShiftX
+	c.ShiftY = dc.ShiftY
+	c.Distortion = dc.Distortion
+	c.FisheyeMap = dc.FisheyeMap
+	c.ShutterOpen = dc.ShutterOpen
+	c.ShutterClose = dc.ShutterClose
+	c.AdaptiveThreshold = dc.AdaptiveThreshold
+	c.AdaptiveDepth = dc.AdaptiveDepth
+	c.Alpha = dc.Alpha
+	switch dc.Filter.Type {
+	case "":
+		c.Filter = nil
+	case "box":
+		c.Filter = &BoxFilter{Radius: dc.Filter.Radius}
+	case "tent":
+		c.Filter = NewTentFilter(dc.Filter.Radius)
+	case "gaussian":
+		c.Filter = NewGaussianFilter(dc.Filter.Radius, dc.Filter.Alpha)
+	case "mitchell":
+		c.Filter = NewMitchellFilter(dc.Filter.Radius, dc.Filter.B, dc.Filter.C)
+	default:
+		return w, c, fmt.Errorf("unknown filter %q", dc.Filter.Type)
+	}
+
+	for _, o := range d.Objects {
+		s, err := o.build()
+		if err != nil {
+			return w, c, err
+		}
+		w.Objects = append(w.Objects, s)
+	}
+	return w, c, nil
+}
+
+func (d ShapeDescription) build() (Shaper, error) {
+	var s Shaper
+	switch d.Type {
+	case "sphere":
+		s = NewSphere()
+	case "plane":
+		s = NewPlane()
+	case "cube":
+		s = NewCube()
+	case "cylinder":
+		t := NewCylinder()
+		t.Minimum, t.Maximum, t.Closed = d.Minimum, d.Maximum, d.Closed
+		s = t
+	case "cone":
+		t := NewCone()
+		t.Minimum, t.Maximum, t.Closed = d.Minimum, d.Maximum, d.Closed
+		s = t
+	case "triangle":
+		s = NewTriangle(d.Points[0], d.Points[1], d.Points[2])
+	case "group":
+		g := NewGroup()
+		for _, child := range d.Children {
+			c, err := child.build()
+			if err != nil {
+				return nil, err
+			}
+			g.AddShape(&c)
+		}
+		s = g
+	default:
+		return nil, fmt.Errorf("unknown shape %q", d.Type)
+	}
+	s.SetTransform(buildMatrix(d.Transform))
+	if d.Moving {
+		s.(Mover).SetEndTransform(buildMatrix(d.EndTransform))
+	}
+	if d.Type == "group" {
+		return s, nil
+	}
+	m, err := d.Material.build()
+	s.SetMaterial(m)
+	return s, err
+}
+
+func (d MaterialDescription) build() (Material, error) {
+	m := NewMaterial()
+	m.Color = d.Color
+	m.Ambient = d.Ambient
+	m.Diffuse = d.Diffuse
+	m.Specular = d.Specular
+	m.Shininess = d.Shininess
+	m.Reflective = d.Reflective
+	m.Transparency = d.Transparency
+	m.RefractiveIndex = d.RefractiveIndex
+	if d.Pattern == nil {
+		return m, nil
+	}
+	var p Pattern
+	switch d.Pattern.Type {
+	case "stripe":
+		p = NewStripePattern(d.Pattern.A, d.Pattern.B)
+	case "gradient":
+		p = NewGradientPattern(d.Pattern.A, d.Pattern.B)
+	case "ring":
+		p = NewRingPattern(d.Pattern.A, d.Pattern.B)
+	case "checker":
+		p = NewCheckerPattern(d.Pattern.A, d.Pattern.B)
+	default:
+		return m, fmt.Errorf("unknown pattern %q", d.Pattern.Type)
+	}
+	p.SetTransform(buildMatrix(d.Pattern.Transform))
+	m.SetPattern(p)
+	return m, nil
+}
+
+// WriteScene encodes the world and camera in Go's gob format.
+func WriteScene(out io.Writer, w World, c Camera) error {
+	d, err := DescribeScene(w, c)
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(out).Encode(d)
+}
+
+// ReadScene decodes a scene written by WriteScene.
+func ReadScene(in io.Reader) (World, Camera, error) {
+	d := SceneDescription{}
+	if err := gob.NewDecoder(in).Decode(&d); err != nil {
+		return World{}, Camera{}, err
+	}
+	return d.Build()
+}
+
+// CopyScene round trips a world and camera through their serialised form.
+func CopyScene(w World, c Camera) (World, Camera, error) {
+	buf := bytes.Buffer{}
+	if err := WriteScene(&buf, w, c); err != nil {
+		return World{}, Camera{}, err
+	}
+	return ReadScene(&buf)
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 type Shaper interface {
 	Equals(t Shaper) bool
 	Intersects(r Ray) map[int]Intersection
@@ -41,15 +46,42 @@ type Shaper interface {
 
 type TestShapeType struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
 	Transform Matrix
 	Material  Material
-	SavedRay  Ray
+	SavedRay  *RayRecorder
 	Parent    *Group
 }
 
+// RayRecorder keeps the last ray handed to a test shape. It lives outside the
+// shape so that intersecting never writes to the shape itself.
+type RayRecorder struct {
+	lock sync.Mutex
+	ray  Ray
+}
+
+func (r *RayRecorder) Record(ray Ray) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.ray = ray
+}
+
+func (r *RayRecorder) Last() Ray {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.ray
+}
+
+var shapeIDs int64
+
+// nextShapeID hands out shape and bounds IDs; safe to call from any goroutine.
+func nextShapeID() int {
+	return int(atomic.AddInt64(&shapeIDs, 1))
+}
+
 var BaseTransform = IdentityMatrix()
 var BaseOrigin = NewPoint(0, 0, 0)
 var BaseMaterial = NewMaterial()
@@ -59,6 +91,7 @@ func NewTestShape() *TestShapeType {
 		Transform: IdentityMatrix(),
 		Origin:    NewPoint(0, 0, 0),
 		Material:  NewMaterial(),
+		SavedRay:  &RayRecorder{},
 		Parent:    nil,
 	}
 }
@@ -91,22 +124,22 @@ func (s *TestShapeType) Equals(t Shaper) bool {
 func (s *TestShapeType) GetType() string { return "test" }
 
 func (s *TestShapeType) GetSavedRay() Ray {
-	return s.SavedRay
+	return s.SavedRay.Last()
 }
 func (s *TestShapeType) Intersects(r Ray) map[int]Intersection {
-	bob := s.GetTransform()
-	localRay := r.Transform(bob.Inverse())
-	s.SavedRay = localRay
-	return s.LocalIntersects(localRay)
+	return Intersect(s, r)
 }
 
 func Intersect(s Shaper, r Ray) map[int]Intersection {
-	sTrans := s.GetTransform()
+	sTrans := TransformAt(s, r.Time)
 	localRay := r.Transform(sTrans.Inverse())
 	return s.LocalIntersects(localRay)
 }
 
 func (s *TestShapeType) LocalIntersects(r Ray) map[int]Intersection {
+	if s.SavedRay != nil {
+		s.SavedRay.Record(r)
+	}
 	return make(map[int]Intersection)
 }
 
@@ -2,11 +2,11 @@ package main
 
 import (
 	"math"
-	"math/rand"
 )
 
 type Sphere struct {
 	Shaper
+	Motion
 	ID        int
 	Origin    Tuple
 	Radius    float64
@@ -15,11 +15,9 @@ type Sphere struct {
 	Parent    *Group
 }
 
-var SphereCount = 0
-
 func NewSphere() *Sphere {
 	return &Sphere{
-		ID:        rand.Intn(100000),
+		ID:        nextShapeID(),
 		Transform: BaseTransform,
 		Origin:    BaseOrigin,
 		Material:  BaseMaterial,
@@ -29,7 +27,7 @@ func NewSphere() *Sphere {
 
 func NewGlassSphere() *Sphere {
 	me := Sphere{
-		ID:        rand.Intn(100000),
+		ID:        nextShapeID(),
 		Transform: BaseTransform,
 		Origin:    BaseOrigin,
 		Material:  BaseMaterial,
@@ -0,0 +1,97 @@
+package main
+
+import "fmt"
+
+// StereoRig renders a pair of views from two eyes either side of Camera,
+// Interocular world units apart. The eyes look parallel and their image
+// windows are shifted so objects Convergence units in front of the rig land
+// in the same place in both views; nearer objects come out of the screen.
+type StereoRig struct {
+	Camera      Camera
+	Interocular float64
+	Convergence float64
+}
+
+var StereoLayouts = []string{"side-by-side", "over-under", "anaglyph"}
+
+func NewStereoRig(c Camera, interocular, convergence float64) StereoRig {
+	return StereoRig{
+		Camera:      c,
+		Interocular: interocular,
+		Convergence: convergence,
+	}
+}
+
+// Eye is the camera for the "left" or "right" eye. Camera space x points to
+// the camera's left, so the left eye sits at +x.
+func (r StereoRig) Eye(side string) Camera {
+	offset := r.Interocular / 2
+	if side == "right" {
+		offset = -offset
+	}
+	eye := r.Camera
+	shift := NewTranslation(-offset, 0, 0)
+	eye.SetTransform(shift.MultiplyMatrix(r.Camera.Transform))
+	if r.Convergence > 0 {
+		eye.ShiftX -= offset / r.Convergence
+	}
+	return eye
+}
+
+func (r StereoRig) Render(w World) (Canvas, Canvas) {
+	left := r.Eye("left")
+	right := r.Eye("right")
+	return left.Render(w), right.Render(w)
+}
+
+// RenderLayout renders both eyes and composes them as one of
+// StereoLayouts.
+func (r StereoRig) RenderLayout(w World, layout string) (Canvas, error) {
+	left, right := r.Render(w)
+	return ComposeStereo(left, right, layout)
+}
+
+// ComposeStereo puts a pair of equal sized views into one canvas. Side by
+// side puts the left eye on the left, over/under puts it on top, and the
+// anaglyph takes red from the left eye and green and blue from the right,
+// for red-cyan glasses. The first two keep the views' alpha; an anaglyph
+// mixes two views into each pixel, so it takes opaque views only.
+func ComposeStereo(left, right Canvas, layout string) (Canvas, error) {
+	if left.Width != right.Width || left.Height != right.Height {
+		return Canvas{}, fmt.Errorf("stereo views are %dx%d and %dx%d", left.Width, left.Height, right.Width, right.Height)
+	}
+	w, h := left.Width, left.Height
+	hasAlpha := left.HasAlpha() || right.HasAlpha()
+	switch layout {
+	case "side-by-side":
+		out := NewCanvas(w*2, h)
+		if hasAlpha {
+			out.AddAlpha()
+		}
+		out.SetRegion(0, 0, left)
+		out.SetRegion(w, 0, right)
+		return out, nil
+	case "over-under":
+		out := NewCanvas(w, h*2)
+		if hasAlpha {
+			out.AddAlpha()
+		}
+		out.SetRegion(0, 0, left)
+		out.SetRegion(0, h, right)
+		return out, nil
+	case "anaglyph":
+		if hasAlpha {
+			return Canvas{}, fmt.Errorf("an anaglyph cannot keep the views' alpha")
+		}
+		out := NewCanvas(w, h)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				l := left.PixelAt(x, y)
+				r := right.PixelAt(x, y)
+				out.WritePixel(x, y, NewColor(l.Red, r.Green, r.Blue))
+			}
+		}
+		return out, nil
+	}
+	return Canvas{}, fmt.Errorf("unknown stereo layout %q", layout)
+}
@@ -13,14 +13,11 @@ type Triangle struct {
 	Parent     *Group
 }
 
-var triangleCount = 0
-
 func NewTriangle(p1, p2, p3 Tuple) *Triangle {
-	triangleCount++
 	e1 := p2.Subtract(p1)
 	e2 := p3.Subtract(p1)
 	return &Triangle{
-		ID:        triangleCount,
+		ID:        nextShapeID(),
 		P1:        p1,
 		P2:        p2,
 		P3:        p3,
@@ -138,8 +135,10 @@ func (s *Triangle) GetTransform() Matrix {
 	return IdentityMatrix()
 }
 
+// Intersects skips inverting the transform, which for a triangle is always
+// the identity; meshes have thousands of them.
 func (s *Triangle) Intersects(r Ray) map[int]Intersection {
-	return Intersect(s, r)
+	return s.LocalIntersects(r)
 }
 
 func (s *Triangle) NormalAt(p Tuple) Tuple {
@@ -86,7 +86,14 @@ func (w *World) Intersect(r Ray) map[int]Intersection {
 var STOPHERE = false
 
 func (w *World) ShadeHit(comps Computations, remaining int) Color {
-	inShadow := w.IsShadowed(comps.OverPoint)
+	color, _ := w.shadeHitCoverage(comps, remaining)
+	return color
+}
+
+// shadeHitCoverage is ShadeHit along with the hit's coverage, taken from
+// the same refracted ray as its colour.
+func (w *World) shadeHitCoverage(comps Computations, remaining int) (Color, float64) {
+	inShadow := w.IsShadowedAt(comps.OverPoint, comps.Time)
 	surface := Lighting(
 		comps.Object.GetMaterial(),
 		comps.Object,
@@ -96,33 +103,48 @@ func (w *World) ShadeHit(comps Computations, remaining int) Color {
 		comps.Normalv,
 		inShadow)
 	reflected := w.ReflectedColor(comps, remaining)
-	refracted := w.RefractedColor(comps, remaining)
+	refracted, behind := w.refractedColorCoverage(comps, remaining)
 
 	material := comps.Object.GetMaterial()
+	coverage := 1.0
+	if material.Transparency > 0 {
+		coverage = (1 - material.Transparency) + material.Transparency*behind
+	}
 	if material.Reflective > 0 && material.Transparency > 0 {
 		reflectance := comps.Schlick()
-		return surface.Add(reflected.MultiplyScalar(reflectance)).Add(refracted.MultiplyScalar(1 - reflectance))
+		return surface.Add(reflected.MultiplyScalar(reflectance)).Add(refracted.MultiplyScalar(1 - reflectance)), coverage
 	}
-	return surface.Add(reflected).Add(refracted)
+	return surface.Add(reflected).Add(refracted), coverage
 }
 
 func (w *World) ColorAt(r Ray, remaining int) Color {
+	color, _ := w.ColorCoverageAt(r, remaining)
+	return color
+}
+
+// ColorCoverageAt is ColorAt and CoverageAt in one pass: the coverage comes
+// from the same hits as the colour.
+func (w *World) ColorCoverageAt(r Ray, remaining int) (Color, float64) {
 	i := w.Intersect(r)
 	hit, is := Hit(i)
 
 	if !hit {
-		return NewColor(0, 0, 0)
+		return NewColor(0, 0, 0), 0
 	}
 	comps := is.PrepareComputations(r, i)
-	return w.ShadeHit(comps, remaining)
+	return w.shadeHitCoverage(comps, remaining)
 }
 
 func (w *World) IsShadowed(p Tuple) bool {
+	return w.IsShadowedAt(p, 0)
+}
+
+func (w *World) IsShadowedAt(p Tuple, time float64) bool {
 	v := w.Lights[0].Position.Subtract(p)
 	distance := v.Magnitude()
 	direction := v.Normalize()
 
-	r := NewRay(p, direction)
+	r := NewRayAt(p, direction, time)
 	intersections := w.Intersect(r)
 
 	ishit, h := Hit(intersections)
@@ -139,44 +161,53 @@ func (w *World) ReflectedColor(comps Computations, remaining int) Color {
 	if comps.Object.GetMaterial().Reflective == 0 {
 		return NewColor(0, 0, 0)
 	}
-	reflectRay := NewRay(comps.OverPoint, comps.Reflectv)
+	reflectRay := NewRayAt(comps.OverPoint, comps.Reflectv, comps.Time)
 	color := w.ColorAt(reflectRay, remaining-1)
 	return color.MultiplyScalar(comps.Object.GetMaterial().Reflective)
 }
 
 func (w *World) RefractedColor(comps Computations, remaining int) Color {
+	color, _ := w.refractedColorCoverage(comps, remaining)
+	return color
+}
+
+// refractedColorCoverage is RefractedColor and the coverage of what the
+// refracted ray sees. Where no ray is traced the surface counts as covering
+// everything behind it.
+func (w *World) refractedColorCoverage(comps Computations, remaining int) (Color, float64) {
 	if remaining < 1 {
-		return NewColor(0, 0, 0)
+		return NewColor(0, 0, 0), 1
 	}
 	if comps.Object.GetMaterial().Transparency == 0 {
-		return NewColor(0, 0, 0)
+		return NewColor(0, 0, 0), 1
+	}
+	refractRay, ok := refractedRay(comps)
+	if !ok {
+		return NewColor(0, 0, 0), 1
 	}
+	color, coverage := w.ColorCoverageAt(refractRay, remaining-1)
+	return color.MultiplyScalar(comps.Object.GetMaterial().Transparency), coverage
+}
+
+// refractedRay bends the eye ray into the hit surface by Snell's law. There
+// is none under total internal reflection.
+func refractedRay(comps Computations) (Ray, bool) {
 	nRatio := comps.N1 / comps.N2
 	cosI := comps.Eyev.DotProduct(comps.Normalv)
 	sin2T := nRatio * nRatio * (1 - cosI*cosI)
 	if sin2T > 1 {
-		return NewColor(0, 0, 0)
+		return Ray{}, false
 	}
 	cosT := math.Sqrt(1.0 - sin2T)
 	direction := comps.Normalv.MultiplyScalar(nRatio*cosI - cosT).Subtract(comps.Eyev.MultiplyScalar(nRatio))
-	refractRay := NewRay(comps.UnderPoint, direction)
-	/*
-		if STOPHERE {
-			c1 := w.ColorAt(refractRay, remaining-1)
-			color := c1.MultiplyScalar(comps.Object.GetMaterial().Transparency)
-			log.Fatalf(
-				"REFRACT FAIL %v\n%v\n%v\nN %f, C %f, S %f, CT %f\nP: %v, D: %v",
-				refractRay,
-				c1,
-				color,
-				nRatio,
-				cosI,
-				sin2T,
-				cosT,
-				comps.UnderPoint,
-				direction)
-		}
-	*/
-	color := w.ColorAt(refractRay, remaining-1).MultiplyScalar(comps.Object.GetMaterial().Transparency)
-	return color
+	return NewRayAt(comps.UnderPoint, direction, comps.Time), true
+}
+
+// CoverageAt is how much of what r sees is the world rather than the
+// background behind it: 0 where it misses and 1 where it hits an opaque
+// surface. A transparent surface covers its opacity plus its transparency
+// times the coverage of the ray refracted through it.
+func (w *World) CoverageAt(r Ray, remaining int) float64 {
+	_, coverage := w.ColorCoverageAt(r, remaining)
+	return coverage
 }